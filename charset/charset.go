@@ -0,0 +1,123 @@
+// Package charset converts the bytes read by inputs to UTF-8.
+package charset
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/transform"
+
+	"github.com/najeira/gigo"
+)
+
+// Policy is the handling of invalid byte sequences.
+type Policy int
+
+const (
+	// Replace replaces an invalid sequence with U+FFFD.
+	Replace Policy = iota
+
+	// Drop removes an invalid sequence.
+	Drop
+
+	// Escape writes the bytes of an invalid sequence as "\xNN".
+	Escape
+)
+
+// ParsePolicy returns the policy named "replace", "drop" or "escape".
+// Empty is Replace.
+func ParsePolicy(name string) (Policy, error) {
+	switch strings.ToLower(name) {
+	case "", "replace":
+		return Replace, nil
+	case "drop":
+		return Drop, nil
+	case "escape":
+		return Escape, nil
+	}
+	return Replace, fmt.Errorf("charset: unknown policy %s", name)
+}
+
+// Decoder converts bytes in an encoding to UTF-8.
+// It is safe for concurrent use.
+type Decoder struct {
+	enc    encoding.Encoding
+	policy Policy
+}
+
+// NewDecoder returns a decoder of the encoding such as "Shift_JIS",
+// "EUC-JP" or "UTF-8", named by the WHATWG labels.
+func NewDecoder(name string, policy Policy) (*Decoder, error) {
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("charset: unknown encoding %s", name)
+	}
+	return &Decoder{enc: enc, policy: policy}, nil
+}
+
+// New returns a decoder by "encoding" and "encoding_invalid".
+// It returns nil if "encoding" is not set.
+func New(config gigo.PluginConfig) (*Decoder, error) {
+	name := config.String("encoding", "")
+	if name == "" {
+		return nil, nil
+	}
+	policy, err := ParsePolicy(config.String("encoding_invalid", ""))
+	if err != nil {
+		return nil, err
+	}
+	return NewDecoder(name, policy)
+}
+
+// Decode returns src converted to UTF-8.
+func (d *Decoder) Decode(src []byte) []byte {
+	dst, _, err := transform.Bytes(d.enc.NewDecoder(), src)
+	if err == nil && (d.policy == Replace || bytes.IndexRune(dst, utf8.RuneError) < 0) {
+		return dst
+	}
+	return d.decodeRunes(src)
+}
+
+// decodeRunes converts src a character at a time
+// to find the bytes of invalid sequences.
+func (d *Decoder) decodeRunes(src []byte) []byte {
+	t := d.enc.NewDecoder()
+	dst := make([]byte, 0, len(src)*2)
+	var buf [utf8.UTFMax * 2]byte
+	for len(src) > 0 {
+		// widen the window until a character is decoded
+		var nDst, nSrc int
+		for n := 1; ; n++ {
+			atEOF := n >= len(src)
+			var err error
+			nDst, nSrc, err = t.Transform(buf[:], src[:n], atEOF)
+			if err != transform.ErrShortSrc || atEOF {
+				break
+			}
+		}
+		if nSrc <= 0 {
+			// not decodable at all
+			nDst, nSrc = copy(buf[:], string(utf8.RuneError)), 1
+		}
+
+		if r, _ := utf8.DecodeRune(buf[:nDst]); nDst <= 0 || r != utf8.RuneError {
+			dst = append(dst, buf[:nDst]...)
+		} else {
+			switch d.policy {
+			case Replace:
+				dst = append(dst, buf[:nDst]...)
+			case Escape:
+				for _, b := range src[:nSrc] {
+					dst = append(dst, fmt.Sprintf(`\x%02x`, b)...)
+				}
+			}
+		}
+		src = src[nSrc:]
+		t.Reset()
+	}
+	return dst
+}
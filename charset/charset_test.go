@@ -0,0 +1,61 @@
+package charset
+
+import (
+	"testing"
+
+	"github.com/najeira/gigo"
+)
+
+func TestDecode(t *testing.T) {
+	// "日本語" in Shift_JIS and EUC-JP
+	sjis := []byte{0x93, 0xfa, 0x96, 0x7b, 0x8c, 0xea}
+	eucjp := []byte{0xc6, 0xfc, 0xcb, 0xdc, 0xb8, 0xec}
+
+	tests := []struct {
+		encoding string
+		policy   Policy
+		input    []byte
+		output   string
+	}{
+		{"Shift_JIS", Replace, sjis, "日本語"},
+		{"sjis", Replace, append([]byte("a "), sjis...), "a 日本語"},
+		{"EUC-JP", Replace, eucjp, "日本語"},
+		{"Shift_JIS", Replace, []byte{'a', 0xa0, 'b'}, "a�b"},
+		{"Shift_JIS", Drop, []byte{'a', 0xa0, 'b'}, "ab"},
+		{"Shift_JIS", Escape, []byte{'a', 0xa0, 'b'}, `a\xa0b`},
+		{"EUC-JP", Escape, append([]byte{0xff}, eucjp...), `\xff日本語`},
+		{"UTF-8", Drop, []byte{'a', 0xff, 'b'}, "ab"},
+		{"UTF-8", Escape, []byte("日本語\xe6"), `日本語\xe6`},
+	}
+	for _, test := range tests {
+		d, err := NewDecoder(test.encoding, test.policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ret := string(d.Decode(test.input)); ret != test.output {
+			t.Errorf("%s %d: invalid decode %q, expected %q",
+				test.encoding, test.policy, ret, test.output)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	d, err := New(gigo.PluginConfig{})
+	if err != nil || d != nil {
+		t.Errorf("decoder without encoding: %v %v", d, err)
+	}
+
+	d, err = New(gigo.PluginConfig{"encoding": "euc-jp", "encoding_invalid": "drop"})
+	if err != nil {
+		t.Fatal(err)
+	} else if d.policy != Drop {
+		t.Errorf("invalid policy %d", d.policy)
+	}
+
+	if _, err := New(gigo.PluginConfig{"encoding": "unknown"}); err == nil {
+		t.Error("no error for unknown encoding")
+	}
+	if _, err := New(gigo.PluginConfig{"encoding": "sjis", "encoding_invalid": "x"}); err == nil {
+		t.Error("no error for unknown policy")
+	}
+}
@@ -1,6 +1,7 @@
 package cloudwatchlogs
 
 import (
+	"errors"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -12,9 +13,22 @@ import (
 
 const (
 	pullInterval = time.Second * 3
+	maxRetryWait = time.Minute
+	readerTag    = "cloudwatchlogs"
 )
 
+var (
+	ErrNoForwardToken = errors.New("cloudwatchlogs: no next forward token")
+
+	_ gigo.Input = (*Reader)(nil)
+)
+
+func init() {
+	gigo.RegisterInput("cloudwatchlogs", newInput)
+}
+
 type ReaderConfig struct {
+	Tag           string
 	Credentials   *credentials.Credentials
 	Region        string
 	Group         string
@@ -29,6 +43,7 @@ type Reader struct {
 	gigo.Mixin
 
 	svc           readerService
+	tag           string
 	group         *string
 	stream        *string
 	nextToken     *string
@@ -37,15 +52,21 @@ type Reader struct {
 	startFromHead *bool
 	nextPullTime  time.Time
 	eventCh       chan *cloudwatchlogs.OutputLogEvent
+	stop          chan struct{}
+	done          chan struct{}
 }
 
 func NewReader(config ReaderConfig) *Reader {
 	r := &Reader{
 		svc:     newClient(config.Region, config.Credentials),
+		tag:     config.Tag,
 		group:   aws.String(config.Group),
 		stream:  aws.String(config.Stream),
 		eventCh: make(chan *cloudwatchlogs.OutputLogEvent),
 	}
+	if r.tag == "" {
+		r.tag = readerTag
+	}
 	if config.NextToken != "" {
 		r.nextToken = aws.String(config.NextToken)
 	}
@@ -61,6 +82,20 @@ func NewReader(config ReaderConfig) *Reader {
 	return r
 }
 
+func newInput(config gigo.PluginConfig) (gigo.Input, error) {
+	if err := config.Require("region", "group", "stream"); err != nil {
+		return nil, err
+	}
+	return NewReader(ReaderConfig{
+		Tag:           config.String("tag", ""),
+		Credentials:   newCredentials(config),
+		Region:        config.String("region", ""),
+		Group:         config.String("group", ""),
+		Stream:        config.String("stream", ""),
+		StartFromHead: config.Bool("start_from_head", false),
+	}), nil
+}
+
 func (r *Reader) Read() (*cloudwatchlogs.OutputLogEvent, error) {
 	for {
 		select {
@@ -77,26 +112,112 @@ func (r *Reader) Read() (*cloudwatchlogs.OutputLogEvent, error) {
 	}
 }
 
+// Start reads events and emits each message as a record to e until Stop.
+func (r *Reader) Start(e gigo.Emitter) error {
+	if r.done != nil {
+		return gigo.ErrAlreadyStarted
+	}
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+	go r.emitEvents(e, r.stop, r.done)
+	return nil
+}
+
+func (r *Reader) emitEvents(e gigo.Emitter, stop, done chan struct{}) {
+	defer close(done)
+	wait := pullInterval
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		event, err := r.Read()
+		if err == ErrClosed {
+			continue
+		} else if err != nil {
+			// back off not to call the API repeatedly while failing
+			r.Error(err)
+			if !sleep(stop, wait) {
+				return
+			}
+			if wait *= 2; wait > maxRetryWait {
+				wait = maxRetryWait
+			}
+			continue
+		}
+		wait = pullInterval
+		record := gigo.NewRecord(r.tag, []byte(aws.StringValue(event.Message)))
+		if event.Timestamp != nil {
+			record.Time = milliToTime(aws.Int64Value(event.Timestamp))
+		}
+		if err := e.Emit(record); err != nil {
+			r.Infof("emit error %s", err)
+		}
+	}
+}
+
+// Stop stops pulling and emitting, and waits for the current pull to finish.
+func (r *Reader) Stop() error {
+	if r.done == nil {
+		return gigo.ErrNotStarted
+	}
+	close(r.stop)
+	<-r.done
+	r.stop = nil
+	r.done = nil
+	return nil
+}
+
+func (r *Reader) Health() error {
+	if r.done == nil {
+		return gigo.ErrNotStarted
+	}
+	return nil
+}
+
+// pullEvents gets the events until any, waiting for pullInterval between
+// the polls. It returns ErrClosed if the reader is stopped while waiting.
 func (r *Reader) pullEvents() error {
 	for {
 		if remain := r.nextPullTime.Sub(time.Now()); remain > 0 {
 			r.Debugf("sleep %s", remain.String())
-			time.Sleep(remain)
+			if !sleep(r.stop, remain) {
+				return ErrClosed
+			}
 		}
 		events, err := r.getEvents()
 		r.nextPullTime = time.Now().Add(pullInterval)
 		if err != nil {
 			return err
 		} else if len(events) > 0 {
-			go r.enqueEvents(events)
+			go r.enqueEvents(events, r.stop)
 			return nil
 		}
 	}
 }
 
-func (r *Reader) enqueEvents(events []*cloudwatchlogs.OutputLogEvent) {
+func (r *Reader) enqueEvents(events []*cloudwatchlogs.OutputLogEvent, stop chan struct{}) {
 	for _, event := range events {
-		r.eventCh <- event
+		select {
+		case r.eventCh <- event:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// sleep waits for d and returns true, or returns false if stop is closed.
+// A nil stop never closes.
+func sleep(stop chan struct{}, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-stop:
+		return false
 	}
 }
 
@@ -113,7 +234,7 @@ func (r *Reader) getEvents() ([]*cloudwatchlogs.OutputLogEvent, error) {
 	if err != nil {
 		return nil, err
 	} else if res.NextForwardToken == nil {
-		panic("nextForwardToken is nil")
+		return nil, ErrNoForwardToken
 	}
 	r.nextToken = res.NextForwardToken
 	r.Infof("get %d events sequence %s", len(res.Events), aws.StringValue(res.NextForwardToken))
@@ -6,6 +6,8 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+
+	"github.com/najeira/gigo"
 )
 
 type testReaderService struct {
@@ -77,3 +79,35 @@ func TestReaderRead(t *testing.T) {
 		}
 	}
 }
+
+func TestReaderStop(t *testing.T) {
+	// no events to wait for the next poll
+	svc := &testReaderService{output: &cloudwatchlogs.GetLogEventsOutput{
+		NextForwardToken: aws.String("forward"),
+	}}
+	r := NewReader(ReaderConfig{Group: "test group", Stream: "test stream"})
+	r.svc = svc
+
+	if err := r.Start(gigo.EmitterFunc(func(*gigo.Record) error { return nil })); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	start := time.Now()
+	if err := r.Stop(); err != nil {
+		t.Error(err)
+	}
+	if d := time.Since(start); d >= pullInterval {
+		t.Errorf("invalid stop %s", d)
+	}
+}
+
+func TestReaderNoForwardToken(t *testing.T) {
+	svc := &testReaderService{output: &cloudwatchlogs.GetLogEventsOutput{}}
+	r := NewReader(ReaderConfig{Group: "test group", Stream: "test stream"})
+	r.svc = svc
+
+	if _, err := r.Read(); err != ErrNoForwardToken {
+		t.Errorf("invalid error %v", err)
+	}
+}
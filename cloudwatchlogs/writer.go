@@ -11,6 +11,7 @@ import (
 	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
 
 	"github.com/najeira/gigo"
+	"github.com/najeira/gigo/formatter"
 )
 
 const (
@@ -25,8 +26,14 @@ const (
 var (
 	ErrClosed = errors.New("cloudwatchlogs: writer closed")
 	ErrSize   = errors.New("cloudwatchlogs: too long")
+
+	_ gigo.Output = (*Writer)(nil)
 )
 
+func init() {
+	gigo.RegisterOutput("cloudwatchlogs", newOutput)
+}
+
 type WriterConfig struct {
 	Credentials *credentials.Credentials
 	Region      string
@@ -35,11 +42,16 @@ type WriterConfig struct {
 	Interval    time.Duration
 	BatchSize   int
 	BatchCount  int
+	Formatter   formatter.Formatter
 }
 
 type Writer struct {
 	gigo.Mixin
 
+	credentials *credentials.Credentials
+	region      string
+	formatter   formatter.Formatter
+
 	svc      writerService
 	group    string
 	stream   string
@@ -55,28 +67,50 @@ type Writer struct {
 }
 
 func NewWriter(config WriterConfig) (*Writer, error) {
-	svc := newClient(config.Region, config.Credentials)
-	sequence, err := createStreamIfNotExists(svc, config.Group, config.Stream)
-	if err != nil {
+	w := newWriter(config)
+	if err := w.Start(); err != nil {
 		return nil, err
 	}
-	w := newWriter(config)
-	w.svc = svc
-	w.sequence = sequence
-	go w.run()
 	return w, nil
 }
 
+func newOutput(config gigo.PluginConfig) (gigo.Output, error) {
+	if err := config.Require("region", "group", "stream"); err != nil {
+		return nil, err
+	}
+	interval, err := config.Duration("interval", defaultInterval)
+	if err != nil {
+		return nil, err
+	}
+	f, err := formatter.New(config)
+	if err != nil {
+		return nil, err
+	}
+	return newWriter(WriterConfig{
+		Credentials: newCredentials(config),
+		Region:      config.String("region", ""),
+		Group:       config.String("group", ""),
+		Stream:      config.String("stream", ""),
+		Interval:    interval,
+		BatchSize:   int(config.Int("batch_size", 0)),
+		BatchCount:  int(config.Int("batch_count", 0)),
+		Formatter:   f,
+	}), nil
+}
+
 func newWriter(config WriterConfig) *Writer {
 	if config.Interval <= 0 {
 		config.Interval = defaultInterval
 	}
 	w := &Writer{
-		group:    config.Group,
-		stream:   config.Stream,
-		interval: config.Interval,
-		eventCh:  make(chan *cloudwatchlogs.InputLogEvent, 100),
-		closed:   make(chan struct{}),
+		credentials: config.Credentials,
+		region:      config.Region,
+		formatter:   config.Formatter,
+		group:       config.Group,
+		stream:      config.Stream,
+		interval:    config.Interval,
+		eventCh:     make(chan *cloudwatchlogs.InputLogEvent, 100),
+		closed:      make(chan struct{}),
 	}
 	if config.BatchSize > 0 {
 		w.batchSize = config.BatchSize
@@ -88,6 +122,9 @@ func newWriter(config WriterConfig) *Writer {
 	} else {
 		w.batchCount = batchCount
 	}
+	if w.formatter == nil {
+		w.formatter = formatter.NewRaw(formatter.RawConfig{})
+	}
 	w.Name = pluginName
 	return w
 }
@@ -113,7 +150,52 @@ func createStreamIfNotExists(client *cloudwatchlogs.CloudWatchLogs, group, strea
 	return nil, err
 }
 
+// Start creates the stream if it does not exist and starts sending events.
+func (w *Writer) Start() error {
+	if w.svc != nil {
+		return gigo.ErrAlreadyStarted
+	}
+	svc := newClient(w.region, w.credentials)
+	sequence, err := createStreamIfNotExists(svc, w.group, w.stream)
+	if err != nil {
+		w.Error(err)
+		return err
+	}
+	w.svc = svc
+	w.sequence = sequence
+	go w.run()
+	return nil
+}
+
+func (w *Writer) Stop() error {
+	return w.Close()
+}
+
+// Emit writes the record formatted by the formatter as a row
+// with the record's time. The trailing newline is trimmed.
+func (w *Writer) Emit(record *gigo.Record) error {
+	data, err := w.formatter.Format(record)
+	if err != nil {
+		w.Error(err)
+		return err
+	}
+	return w.write(strings.TrimSuffix(string(data), "\n"), record.Time)
+}
+
+func (w *Writer) Health() error {
+	if w.closed == nil {
+		return ErrClosed
+	} else if w.svc == nil {
+		return gigo.ErrNotStarted
+	}
+	return nil
+}
+
 func (w *Writer) Write(msg string) error {
+	return w.write(msg, time.Now())
+}
+
+func (w *Writer) write(msg string, t time.Time) error {
 	if w.closed == nil {
 		w.Info(ErrClosed)
 		return ErrClosed
@@ -122,7 +204,7 @@ func (w *Writer) Write(msg string) error {
 	}
 	event := &cloudwatchlogs.InputLogEvent{
 		Message:   aws.String(msg),
-		Timestamp: aws.Int64(timeToMilli(time.Now())),
+		Timestamp: aws.Int64(timeToMilli(t)),
 	}
 	w.eventCh <- event
 	w.Debugf("write a row %d bytes", len(msg))
@@ -137,7 +219,6 @@ func (w *Writer) run() {
 			return
 		}
 	}
-	panic("fuga")
 }
 
 func (w *Writer) pull(eventCh <-chan *cloudwatchlogs.InputLogEvent, timer *time.Timer) bool {
@@ -233,6 +314,15 @@ func newClient(region string, credentials *credentials.Credentials) *cloudwatchl
 	return cloudwatchlogs.New(sess)
 }
 
+func newCredentials(config gigo.PluginConfig) *credentials.Credentials {
+	key := config.String("key", "")
+	secret := config.String("secret", "")
+	if key == "" || secret == "" {
+		return nil
+	}
+	return credentials.NewStaticCredentials(key, secret, "")
+}
+
 type writerService interface {
 	PutLogEvents(*cloudwatchlogs.PutLogEventsInput) (*cloudwatchlogs.PutLogEventsOutput, error)
 }
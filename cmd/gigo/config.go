@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/najeira/conv"
+	"github.com/pelletier/go-toml"
+
+	"github.com/najeira/gigo"
+)
+
+const (
+	defaultMatch = "**"
+)
+
+type Config struct {
+	LogLevel string
+	Inputs   []PluginConfig
+	Filters  []PluginConfig
+	Outputs  []PluginConfig
+}
+
+// PluginConfig is a [[input]], [[filter]] or [[output]] block.
+type PluginConfig struct {
+	Type     string
+	Match    string
+	LogLevel string
+	Settings gigo.PluginConfig
+}
+
+func LoadConfig(file string) (*Config, error) {
+	rootTree, err := toml.LoadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{}
+	config.LogLevel = conv.String(rootTree.Get("log_level"), "error")
+
+	if config.Inputs, err = loadPlugins(rootTree, "input", config.LogLevel); err != nil {
+		return nil, err
+	}
+	if config.Filters, err = loadPlugins(rootTree, "filter", config.LogLevel); err != nil {
+		return nil, err
+	}
+	if config.Outputs, err = loadPlugins(rootTree, "output", config.LogLevel); err != nil {
+		return nil, err
+	}
+
+	if len(config.Inputs) <= 0 {
+		return nil, errors.New("input is not configured")
+	}
+	if len(config.Outputs) <= 0 {
+		return nil, errors.New("output is not configured")
+	}
+	return config, nil
+}
+
+func loadPlugins(rootTree *toml.TomlTree, key string, logLevel string) ([]PluginConfig, error) {
+	value := rootTree.Get(key)
+	if value == nil {
+		return nil, nil
+	}
+	trees, ok := value.([]*toml.TomlTree)
+	if !ok {
+		return nil, fmt.Errorf("%s must be an array of tables [[%s]]", key, key)
+	}
+
+	plugins := make([]PluginConfig, 0, len(trees))
+	for i, tree := range trees {
+		settings := gigo.PluginConfig(tree.ToMap())
+		plugin := PluginConfig{
+			Type:     settings.String("type", ""),
+			Match:    settings.String("match", defaultMatch),
+			LogLevel: settings.String("log_level", logLevel),
+			Settings: settings,
+		}
+		if plugin.Type == "" {
+			return nil, fmt.Errorf("type is not configured in %s #%d", key, i+1)
+		}
+		plugins = append(plugins, plugin)
+	}
+	return plugins, nil
+}
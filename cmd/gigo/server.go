@@ -0,0 +1,166 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/najeira/gigo"
+	"github.com/najeira/gigo/parser"
+
+	// plugins
+	_ "github.com/najeira/gigo/cloudwatchlogs"
+	_ "github.com/najeira/gigo/filter"
+	_ "github.com/najeira/gigo/in_forward"
+	_ "github.com/najeira/gigo/in_http"
+	_ "github.com/najeira/gigo/in_net"
+	_ "github.com/najeira/gigo/in_syslog"
+	_ "github.com/najeira/gigo/in_tail"
+	_ "github.com/najeira/gigo/out_bigquery"
+	_ "github.com/najeira/gigo/out_file"
+	_ "github.com/najeira/gigo/out_fluent"
+	_ "github.com/najeira/gigo/out_s3"
+)
+
+const (
+	commandName = "gigo"
+)
+
+var (
+	trapSignals = []os.Signal{
+		syscall.SIGHUP,
+		syscall.SIGINT,
+		syscall.SIGTERM,
+		syscall.SIGQUIT}
+)
+
+// loggable is implemented by plugins embedding gigo.Mixin.
+type loggable interface {
+	SetLogging(fn gigo.LogFunc, lvl string)
+}
+
+type server struct {
+	gigo.Mixin
+
+	config   Config
+	logger   *logger
+	pipeline *gigo.Pipeline
+	stopped  chan struct{}
+}
+
+func newServer(config Config, logger *logger) *server {
+	s := server{config: config, logger: logger}
+	s.Name = commandName
+	s.SetLogging(logger.Output, config.LogLevel)
+	return &s
+}
+
+func (s *server) setLogging(plugin interface{}, logLevel string) {
+	if l, ok := plugin.(loggable); ok {
+		l.SetLogging(s.logger.Output, logLevel)
+	}
+}
+
+// pluginConfig returns the settings of c with the logger of c.LogLevel
+// for the plugins taking a Logger by their Config.
+func (s *server) pluginConfig(c PluginConfig) gigo.PluginConfig {
+	c.Settings.SetLogger(gigo.NewLogger(s.logger.Output, c.LogLevel))
+	return c.Settings
+}
+
+func (s *server) build() error {
+	s.pipeline = gigo.NewPipeline()
+	s.setLogging(s.pipeline, s.config.LogLevel)
+
+	for _, c := range s.config.Inputs {
+		input, err := gigo.NewInput(c.Type, s.pluginConfig(c))
+		if err != nil {
+			return err
+		}
+		s.setLogging(input, c.LogLevel)
+		if c.Settings.Has("format") {
+			p, err := parser.New(c.Settings)
+			if err != nil {
+				return err
+			}
+			input = parser.Input(input, p)
+		}
+		s.pipeline.AddInput(input)
+		s.Debugf("input %s", c.Type)
+	}
+
+	for _, c := range s.config.Filters {
+		filter, err := gigo.NewFilter(c.Type, s.pluginConfig(c))
+		if err != nil {
+			return err
+		}
+		s.setLogging(filter, c.LogLevel)
+		if err := s.pipeline.AddFilter(c.Match, filter); err != nil {
+			return err
+		}
+		s.Debugf("filter %s match %s", c.Type, c.Match)
+	}
+
+	for _, c := range s.config.Outputs {
+		output, err := gigo.NewOutput(c.Type, s.pluginConfig(c))
+		if err != nil {
+			return err
+		}
+		s.setLogging(output, c.LogLevel)
+		if err := s.pipeline.AddOutput(c.Match, output); err != nil {
+			return err
+		}
+		s.Debugf("output %s match %s", c.Type, c.Match)
+	}
+	return nil
+}
+
+func (s *server) run() error {
+	if err := s.build(); err != nil {
+		s.Error(err)
+		return err
+	}
+
+	s.Info("start")
+	if err := s.pipeline.Start(); err != nil {
+		s.Error(err)
+		return err
+	}
+
+	s.stopped = make(chan struct{})
+	go s.waitSignals()
+	<-s.stopped
+
+	s.Info("end")
+	return nil
+}
+
+func (s *server) waitSignals() {
+	defer close(s.stopped)
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, trapSignals...)
+	for sig := range sigCh {
+		s.Infof("signal %s", sig)
+		if sig != syscall.SIGHUP {
+			break
+		}
+		// reload certificates and so on
+		if err := s.pipeline.Reload(); err != nil {
+			s.Error(err)
+		}
+	}
+
+	go func() {
+		time.Sleep(10 * time.Second)
+		if sig, ok := <-sigCh; ok {
+			s.Errorf("signal %s before shutdown completed", sig)
+			os.Exit(1)
+		}
+	}()
+
+	if err := s.pipeline.Stop(); err != nil {
+		s.Error(err)
+	}
+}
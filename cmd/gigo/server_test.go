@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/najeira/gigo"
+)
+
+func TestServerLogging(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gigo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var buf bytes.Buffer
+	s := newServer(Config{
+		LogLevel: "error",
+		Inputs: []PluginConfig{{
+			Type:     "net",
+			Match:    defaultMatch,
+			LogLevel: "error",
+			Settings: gigo.PluginConfig{"addr": "127.0.0.1:0"},
+		}},
+		Outputs: []PluginConfig{{
+			Type:     "file",
+			Match:    defaultMatch,
+			LogLevel: "warn",
+			Settings: gigo.PluginConfig{"path": filepath.Join(dir, "none", "out.log")},
+		}},
+	}, &logger{log.New(&buf, "", 0)})
+
+	if err := s.build(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.pipeline.Start(); err == nil {
+		s.pipeline.Stop()
+		t.Fatal("no error for the missing directory")
+	}
+
+	logs := buf.String()
+	if !strings.Contains(logs, "[warn] out_file: open error") {
+		t.Errorf("plugin error is not logged: %s", logs)
+	}
+	if strings.Contains(logs, "[info]") || strings.Contains(logs, "[debug]") {
+		t.Errorf("invalid log level: %s", logs)
+	}
+}
@@ -5,6 +5,9 @@ import (
 
 	"github.com/najeira/conv"
 	"github.com/pelletier/go-toml"
+
+	"github.com/najeira/gigo/in_tail"
+	"github.com/najeira/gigo/out_s3"
 )
 
 type Config struct {
@@ -15,6 +18,8 @@ type Config struct {
 	LogLevelTail string
 	File         string
 	Tail         string
+	MaxLineSize  int
+	Oversize     in_tail.Oversize
 
 	// S3
 	LogLevelS3        string
@@ -43,6 +48,11 @@ func LoadConfig(file string) (*Config, error) {
 	config.LogLevelTail = conv.String(tailTree.Get("log_level"), config.LogLevel)
 	config.File = conv.String(tailTree.Get("file"), "")
 	config.Tail = conv.String(tailTree.Get("tail"), "")
+	config.MaxLineSize = int(conv.Int(tailTree.Get("max_line_size"), 0))
+	config.Oversize, err = in_tail.ParseOversize(conv.String(tailTree.Get("oversize"), ""))
+	if err != nil {
+		return nil, err
+	}
 
 	s3Tree := rootTree.Get("s3").(*toml.TomlTree)
 	config.LogLevelS3 = conv.String(s3Tree.Get("log_level"), config.LogLevel)
@@ -54,9 +64,9 @@ func LoadConfig(file string) (*Config, error) {
 	config.Hostname = conv.Bool(s3Tree.Get("hostname"), false)
 	config.PublicRead = conv.Bool(s3Tree.Get("public_read"), false)
 	config.ReducedRedundancy = conv.Bool(s3Tree.Get("reduced_redundancy"), false)
-	config.TimeFormat = conv.String(s3Tree.Get("time_format"), DefaultTimeFormat)
-	config.BufferSize = int(conv.Int(s3Tree.Get("buffer_size"), DefaultBufferSize))
-	config.FlushInterval = conv.Int(s3Tree.Get("flush_interval"), DefaultFlushInterval)
+	config.TimeFormat = conv.String(s3Tree.Get("time_format"), out_s3.DefaultTimeFormat)
+	config.BufferSize = int(conv.Int(s3Tree.Get("buffer_size"), out_s3.DefaultBufferSize))
+	config.FlushInterval = conv.Int(s3Tree.Get("flush_interval"), out_s3.DefaultFlushInterval)
 
 	if config.File == "" {
 		return nil, errors.New("file is not configured")
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"bufio"
 	"os"
 	"os/signal"
 	"runtime/pprof"
@@ -10,6 +9,7 @@ import (
 
 	"github.com/najeira/gigo"
 	"github.com/najeira/gigo/in_tail"
+	"github.com/najeira/gigo/out_s3"
 )
 
 const (
@@ -22,40 +22,36 @@ var (
 		syscall.SIGINT,
 		syscall.SIGTERM,
 		syscall.SIGQUIT}
-	lineEnd = []byte{'\n'}
 )
 
 type inTailOutS3 struct {
 	gigo.Mixin
 
-	config Config
-	input  *in_tail.Reader
-	output *Writer
+	config   Config
+	logger   *logger
+	pipeline *gigo.Pipeline
+	stopped  chan struct{}
 }
 
 func newInTailOutS3(config Config, logger *logger) *inTailOutS3 {
-	p := inTailOutS3{config: config}
+	p := inTailOutS3{config: config, logger: logger}
 	p.Name = commandName
-	p.logLevel = gigo.parseLogLevel(config.LogLevel)
-	p.logger = logger
+	p.SetLogging(logger.Output, config.LogLevel)
 	return &p
 }
 
-func (p *inTailOutS3) initInput() error {
+func (p *inTailOutS3) newInput() gigo.Input {
 	input := in_tail.New(in_tail.Config{
-		File: p.config.File,
+		File:        p.config.File,
+		MaxLineSize: p.config.MaxLineSize,
+		Oversize:    p.config.Oversize,
 	})
-	input.logLevel = gigo.parseLogLevel(p.config.LogLevelTail)
-	input.logger = p.logger
-	if err := input.Open(); err != nil {
-		return err
-	}
-	p.input = input
-	return nil
+	input.SetLogging(p.logger.Output, p.config.LogLevelTail)
+	return input
 }
 
-func (p *inTailOutS3) initOutput() error {
-	output, err := NewWriter(WriterConfig{
+func (p *inTailOutS3) newOutput() (gigo.Output, error) {
+	output, err := out_s3.NewBufferedWriter(out_s3.BufferedConfig{
 		Key:               p.config.Key,
 		Secret:            p.config.Secret,
 		Region:            p.config.Region,
@@ -69,20 +65,23 @@ func (p *inTailOutS3) initOutput() error {
 		FlushInterval:     p.config.FlushInterval,
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
-	output.logLevel = gigo.parseLogLevel(p.config.LogLevelS3)
-	output.logger = p.logger
-	p.output = output
-	return nil
+	output.SetLogging(p.logger.Output, p.config.LogLevelS3)
+	return output, nil
 }
 
 func (p *inTailOutS3) run() error {
-	if err := p.initInput(); err != nil {
+	output, err := p.newOutput()
+	if err != nil {
 		p.Error(err)
 		return err
 	}
-	if err := p.initOutput(); err != nil {
+
+	p.pipeline = gigo.NewPipeline()
+	p.pipeline.SetLogging(p.logger.Output, p.config.LogLevel)
+	p.pipeline.AddInput(p.newInput())
+	if err := p.pipeline.AddOutput("**", output); err != nil {
 		p.Error(err)
 		return err
 	}
@@ -97,33 +96,24 @@ func (p *inTailOutS3) run() error {
 		pprof.StartCPUProfile(f)
 	}
 
-	go p.waitSignals()
+	p.Info("start")
+	if err := p.pipeline.Start(); err != nil {
+		p.Error(err)
+		return err
+	}
 
-	p.loop()
+	p.stopped = make(chan struct{})
+	go p.waitSignals()
+	<-p.stopped
 
 	pprof.StopCPUProfile()
-	return nil
-}
-
-func (p *inTailOutS3) loop() {
-	p.Info("start")
-	scanner := bufio.NewScanner(p.input)
-	for scanner.Scan() {
-		//fmt.Println(scanner.Text())
-		data := scanner.Bytes()
-		if len(data) > 0 {
-			p.output.Write(data)
-			p.output.Write(lineEnd)
-		}
-	}
-	if err := scanner.Err(); err != nil {
-		p.Info(err)
-	}
-	p.output.Close()
 	p.Info("end")
+	return nil
 }
 
 func (p *inTailOutS3) waitSignals() {
+	defer close(p.stopped)
+
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, trapSignals...)
 	if sig, ok := <-sigCh; ok {
@@ -138,7 +128,7 @@ func (p *inTailOutS3) waitSignals() {
 		}
 	}()
 
-	if err := p.input.Close(); err != nil {
+	if err := p.pipeline.Stop(); err != nil {
 		p.Error(err)
 	}
 }
@@ -7,6 +7,7 @@ import (
 
 const (
 	logError logLevel = iota
+	logWarn
 	logInfo
 	logDebug
 	logNo
@@ -20,6 +21,8 @@ func (lvl logLevel) String() string {
 		return "debug"
 	case logInfo:
 		return "info"
+	case logWarn:
+		return "warn"
 	case logError:
 		return "error"
 	}
@@ -32,8 +35,8 @@ func parseLogLevel(level string) logLevel {
 		switch c {
 		case 'e':
 			return logError
-		case 'w': // warning to error
-			return logError
+		case 'w':
+			return logWarn
 		case 'i':
 			return logInfo
 		case 'd':
@@ -45,16 +48,18 @@ func parseLogLevel(level string) logLevel {
 	return logNo
 }
 
-type Logger func(calldepth int, s string) error
+// LogFunc outputs a line of log such as log.Logger.Output.
+type LogFunc func(calldepth int, s string) error
 
+// Mixin implements Logger for the plugins embedding it.
 type Mixin struct {
 	Name string
 
-	logger   Logger
+	logger   LogFunc
 	logLevel logLevel
 }
 
-func (m *Mixin) SetLogging(fn Logger, lvl string) {
+func (m *Mixin) SetLogging(fn LogFunc, lvl string) {
 	m.logger = fn
 	m.logLevel = parseLogLevel(lvl)
 }
@@ -75,6 +80,14 @@ func (m *Mixin) Infof(format string, args ...interface{}) {
 	m.outputf(logInfo, format, args...)
 }
 
+func (m *Mixin) Warn(args ...interface{}) {
+	m.output(logWarn, args...)
+}
+
+func (m *Mixin) Warnf(format string, args ...interface{}) {
+	m.outputf(logWarn, format, args...)
+}
+
 func (m *Mixin) Error(args ...interface{}) {
 	m.output(logError, args...)
 }
@@ -105,5 +118,9 @@ func (m *Mixin) logLevelName(level logLevel) string {
 }
 
 func (m *Mixin) printToLogger(name string, msg string) {
+	if m.Name == "" {
+		m.logger(5, fmt.Sprintf("[%s] %s", name, msg))
+		return
+	}
 	m.logger(5, fmt.Sprintf("[%s] %s: %s", name, m.Name, msg))
 }
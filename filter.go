@@ -0,0 +1,15 @@
+package gigo
+
+// Filter modifies records between inputs and outputs.
+// Filter returns nil to drop the record.
+// Filters are called from the goroutines of inputs concurrently.
+type Filter interface {
+	Filter(record *Record) (*Record, error)
+}
+
+// FilterFunc adapts a function to the Filter interface.
+type FilterFunc func(record *Record) (*Record, error)
+
+func (f FilterFunc) Filter(record *Record) (*Record, error) {
+	return f(record)
+}
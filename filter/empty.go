@@ -0,0 +1,64 @@
+package filter
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/najeira/gigo"
+)
+
+func init() {
+	gigo.RegisterFilter("drop_empty", newDropEmptyFilter)
+}
+
+var (
+	_ gigo.Filter = (*DropEmpty)(nil)
+)
+
+type DropEmptyConfig struct {
+	// Key is the field to check. The raw bytes are checked if empty.
+	Key string
+}
+
+// DropEmpty drops records whose value is empty or only white spaces.
+// Without Key, a record having no raw bytes is dropped if it has no fields.
+type DropEmpty struct {
+	key string
+}
+
+func NewDropEmpty(config DropEmptyConfig) *DropEmpty {
+	return &DropEmpty{key: config.Key}
+}
+
+func newDropEmptyFilter(config gigo.PluginConfig) (gigo.Filter, error) {
+	return NewDropEmpty(DropEmptyConfig{
+		Key: config.String("key", ""),
+	}), nil
+}
+
+func (f *DropEmpty) Filter(record *gigo.Record) (*gigo.Record, error) {
+	if f.isEmpty(record) {
+		return nil, nil
+	}
+	return record, nil
+}
+
+func (f *DropEmpty) isEmpty(record *gigo.Record) bool {
+	if f.key != "" {
+		v, ok := record.Get(f.key)
+		if !ok || v == nil {
+			return true
+		}
+		switch s := v.(type) {
+		case string:
+			return len(strings.TrimSpace(s)) <= 0
+		case []byte:
+			return len(bytes.TrimSpace(s)) <= 0
+		}
+		return false
+	}
+	if record.Raw != nil {
+		return len(bytes.TrimSpace(record.Raw)) <= 0
+	}
+	return len(record.Fields) <= 0
+}
@@ -0,0 +1,149 @@
+package filter
+
+import (
+	"os"
+	"testing"
+
+	"github.com/najeira/gigo"
+)
+
+func TestRecord(t *testing.T) {
+	f := NewRecord(RecordConfig{
+		Add:    map[string]interface{}{"env": "prod"},
+		Remove: []string{"secret"},
+		Rename: map[string]string{"msg": "message"},
+	})
+
+	r := gigo.NewRecord("tag", nil)
+	r.Set("msg", "hello")
+	r.Set("secret", "xxx")
+
+	r, err := f.Filter(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := r.GetString("message"); v != "hello" {
+		t.Errorf("invalid message: %s", v)
+	}
+	if _, ok := r.Get("msg"); ok {
+		t.Error("msg not renamed")
+	}
+	if _, ok := r.Get("secret"); ok {
+		t.Error("secret not removed")
+	}
+	if !r.Modified {
+		t.Error("not modified")
+	}
+	if v := r.GetString("env"); v != "prod" {
+		t.Errorf("invalid env: %s", v)
+	}
+}
+
+func TestHostname(t *testing.T) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Skip(err)
+	}
+
+	f, err := NewHostname(HostnameConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := f.Filter(gigo.NewRecord("tag", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := r.GetString("hostname"); v != hostname {
+		t.Errorf("invalid hostname: %s", v)
+	}
+	if !r.Modified {
+		t.Error("not modified")
+	}
+}
+
+func TestGrep(t *testing.T) {
+	f, err := NewGrep(GrepConfig{
+		Include: "^GET ",
+		Exclude: "/health",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		raw  string
+		keep bool
+	}{
+		{"GET /index", true},
+		{"POST /index", false},
+		{"GET /health", false},
+	}
+	for _, tt := range tests {
+		r, err := f.Filter(gigo.NewRecord("tag", []byte(tt.raw)))
+		if err != nil {
+			t.Error(err)
+		} else if keep := r != nil; keep != tt.keep {
+			t.Errorf("%s: keep %v", tt.raw, keep)
+		}
+	}
+
+	f, err = NewGrep(GrepConfig{Key: "level", Include: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gigo.NewRecord("tag", nil)
+	r.Set("level", "error")
+	if r, _ := f.Filter(r); r == nil {
+		t.Error("dropped")
+	}
+	r.Set("level", "info")
+	if r, _ := f.Filter(r); r != nil {
+		t.Error("not dropped")
+	}
+
+	// a field not string is matched as formatted
+	f, err = NewGrep(GrepConfig{Key: "status", Include: "^5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Set("status", 503)
+	if r, _ := f.Filter(r); r == nil {
+		t.Error("dropped")
+	}
+	r.Set("status", 200.0)
+	if r, _ := f.Filter(r); r != nil {
+		t.Error("not dropped")
+	}
+	delete(r.Fields, "status")
+	if r, _ := f.Filter(r); r != nil {
+		t.Error("not dropped without the field")
+	}
+
+	if _, err := NewGrep(GrepConfig{Include: "("}); err == nil {
+		t.Error("no error for invalid regexp")
+	}
+}
+
+func TestDropEmpty(t *testing.T) {
+	f := NewDropEmpty(DropEmptyConfig{})
+	if r, _ := f.Filter(gigo.NewRecord("tag", []byte(" \t"))); r != nil {
+		t.Error("blank raw not dropped")
+	}
+	if r, _ := f.Filter(gigo.NewRecord("tag", []byte("a"))); r == nil {
+		t.Error("raw dropped")
+	}
+	if r, _ := f.Filter(gigo.NewRecord("tag", nil)); r != nil {
+		t.Error("no fields not dropped")
+	}
+
+	f = NewDropEmpty(DropEmptyConfig{Key: "message"})
+	r := gigo.NewRecord("tag", nil)
+	if r, _ := f.Filter(r); r != nil {
+		t.Error("missing field not dropped")
+	}
+	r.Set("message", "ok")
+	if r, _ := f.Filter(r); r == nil {
+		t.Error("field dropped")
+	}
+}
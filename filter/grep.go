@@ -0,0 +1,89 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/najeira/gigo"
+)
+
+func init() {
+	gigo.RegisterFilter("grep", newGrepFilter)
+}
+
+var (
+	_ gigo.Filter = (*Grep)(nil)
+)
+
+type GrepConfig struct {
+	// Key is the field to match. The raw bytes are matched if empty.
+	// A field not string is matched as formatted by fmt.Sprint.
+	Key string
+
+	// Include keeps only the records matching the pattern if not empty.
+	Include string
+
+	// Exclude drops the records matching the pattern if not empty.
+	Exclude string
+}
+
+// Grep keeps or drops records by regular expressions.
+type Grep struct {
+	key     string
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+func NewGrep(config GrepConfig) (*Grep, error) {
+	f := &Grep{key: config.Key}
+	if config.Include != "" {
+		re, err := regexp.Compile(config.Include)
+		if err != nil {
+			return nil, err
+		}
+		f.include = re
+	}
+	if config.Exclude != "" {
+		re, err := regexp.Compile(config.Exclude)
+		if err != nil {
+			return nil, err
+		}
+		f.exclude = re
+	}
+	return f, nil
+}
+
+func newGrepFilter(config gigo.PluginConfig) (gigo.Filter, error) {
+	f, err := NewGrep(GrepConfig{
+		Key:     config.String("key", ""),
+		Include: config.String("include", ""),
+		Exclude: config.String("exclude", ""),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *Grep) Filter(record *gigo.Record) (*gigo.Record, error) {
+	var value []byte
+	if f.key == "" {
+		value = record.Raw
+	} else if v, ok := record.Get(f.key); ok {
+		switch v := v.(type) {
+		case string:
+			value = []byte(v)
+		case []byte:
+			value = v
+		default:
+			value = []byte(fmt.Sprint(v))
+		}
+	}
+	if f.include != nil && !f.include.Match(value) {
+		return nil, nil
+	}
+	if f.exclude != nil && f.exclude.Match(value) {
+		return nil, nil
+	}
+	return record, nil
+}
@@ -0,0 +1,61 @@
+package filter
+
+import (
+	"os"
+
+	"github.com/najeira/gigo"
+)
+
+const (
+	defaultHostnameKey = "hostname"
+)
+
+func init() {
+	gigo.RegisterFilter("hostname", newHostnameFilter)
+}
+
+var (
+	_ gigo.Filter = (*Hostname)(nil)
+)
+
+type HostnameConfig struct {
+	// Key is the field name. Default is "hostname".
+	Key string
+}
+
+// Hostname sets the hostname of the machine to a field.
+type Hostname struct {
+	key      string
+	hostname string
+}
+
+func NewHostname(config HostnameConfig) (*Hostname, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	f := &Hostname{
+		key:      config.Key,
+		hostname: hostname,
+	}
+	if f.key == "" {
+		f.key = defaultHostnameKey
+	}
+	return f, nil
+}
+
+func newHostnameFilter(config gigo.PluginConfig) (gigo.Filter, error) {
+	f, err := NewHostname(HostnameConfig{
+		Key: config.String("key", ""),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *Hostname) Filter(record *gigo.Record) (*gigo.Record, error) {
+	record.Set(f.key, f.hostname)
+	record.Modified = true
+	return record, nil
+}
@@ -0,0 +1,71 @@
+package filter
+
+import (
+	"github.com/najeira/conv"
+
+	"github.com/najeira/gigo"
+)
+
+func init() {
+	gigo.RegisterFilter("record", newRecordFilter)
+}
+
+var (
+	_ gigo.Filter = (*Record)(nil)
+)
+
+type RecordConfig struct {
+	// Add sets the fields, overwriting existing values.
+	Add map[string]interface{}
+
+	// Remove deletes the fields.
+	Remove []string
+
+	// Rename renames the fields from the keys to the values.
+	Rename map[string]string
+}
+
+// Record adds, removes and renames fields.
+// Fields are renamed first, then removed, then added.
+type Record struct {
+	add    map[string]interface{}
+	remove []string
+	rename map[string]string
+}
+
+func NewRecord(config RecordConfig) *Record {
+	return &Record{
+		add:    config.Add,
+		remove: config.Remove,
+		rename: config.Rename,
+	}
+}
+
+func newRecordFilter(config gigo.PluginConfig) (gigo.Filter, error) {
+	rename := make(map[string]string)
+	for from, to := range config.Map("rename") {
+		rename[from] = conv.String(to, "")
+	}
+	return NewRecord(RecordConfig{
+		Add:    config.Map("add"),
+		Remove: config.Strings("remove"),
+		Rename: rename,
+	}), nil
+}
+
+func (f *Record) Filter(record *gigo.Record) (*gigo.Record, error) {
+	for from, to := range f.rename {
+		if v, ok := record.Get(from); ok {
+			record.Delete(from)
+			record.Set(to, v)
+		}
+	}
+	for _, key := range f.remove {
+		record.Delete(key)
+	}
+	for key, v := range f.add {
+		record.Set(key, v)
+	}
+	record.Modified = true
+	return record, nil
+}
@@ -0,0 +1,76 @@
+package formatter
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"unicode/utf8"
+
+	"github.com/najeira/gigo"
+)
+
+func init() {
+	Register("csv", newCSVFormatter)
+}
+
+type CSVConfig struct {
+	Config
+
+	// Columns are the fields to write in order.
+	Columns []string
+
+	// Delimiter is the field delimiter. Default is ','.
+	Delimiter rune
+}
+
+// NewCSV returns a formatter writing the fields of the columns as a CSV line.
+// A missing field is written as an empty value.
+func NewCSV(config CSVConfig) (Formatter, error) {
+	if len(config.Columns) <= 0 {
+		return nil, errors.New("formatter: csv columns are not configured")
+	}
+	f := &csvFormatter{
+		config:    config.Config,
+		columns:   config.Columns,
+		delimiter: config.Delimiter,
+	}
+	if f.delimiter == 0 {
+		f.delimiter = ','
+	}
+	return f, nil
+}
+
+type csvFormatter struct {
+	config    Config
+	columns   []string
+	delimiter rune
+}
+
+func newCSVFormatter(config gigo.PluginConfig) (Formatter, error) {
+	delimiter, _ := utf8.DecodeRuneInString(config.String("delimiter", ","))
+	return NewCSV(CSVConfig{
+		Config:    newConfig(config),
+		Columns:   config.Strings("columns"),
+		Delimiter: delimiter,
+	})
+}
+
+func (f *csvFormatter) Format(record *gigo.Record) ([]byte, error) {
+	fields := f.config.fields(record)
+	values := make([]string, len(f.columns))
+	for i, column := range f.columns {
+		values[i] = toString(fields[column])
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Comma = f.delimiter
+	if err := w.Write(values); err != nil {
+		return nil, err
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
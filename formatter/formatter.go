@@ -0,0 +1,101 @@
+package formatter
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/najeira/gigo"
+)
+
+var (
+	lineEnd = []byte{'\n'}
+)
+
+// Formatter serializes a record for outputs.
+// Text formats end with a newline.
+type Formatter interface {
+	Format(record *gigo.Record) ([]byte, error)
+}
+
+// Config is the common configuration of formatters writing fields.
+type Config struct {
+	// TagKey adds the tag of the record as the field if not empty.
+	TagKey string
+
+	// TimeKey adds the time of the record as the field if not empty.
+	TimeKey string
+
+	// TimeLayout is a layout for time.Format, or "unix" and "unixmilli"
+	// for epoch numbers. Default is time.RFC3339.
+	TimeLayout string
+}
+
+// fields returns the fields of the record with the tag and the time.
+func (c *Config) fields(record *gigo.Record) map[string]interface{} {
+	if c.TagKey == "" && c.TimeKey == "" {
+		return record.Fields
+	}
+	fields := make(map[string]interface{}, len(record.Fields)+2)
+	for key, value := range record.Fields {
+		fields[key] = value
+	}
+	if c.TagKey != "" {
+		fields[c.TagKey] = record.Tag
+	}
+	if c.TimeKey != "" {
+		fields[c.TimeKey] = c.formatTime(record.Time)
+	}
+	return fields
+}
+
+func (c *Config) formatTime(t time.Time) interface{} {
+	switch c.TimeLayout {
+	case "":
+		return t.Format(time.RFC3339)
+	case "unix":
+		return t.Unix()
+	case "unixmilli":
+		return t.UnixNano() / int64(time.Millisecond)
+	}
+	return t.Format(c.TimeLayout)
+}
+
+// Factory creates a formatter from the settings of a plugin.
+type Factory func(config gigo.PluginConfig) (Formatter, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[string]Factory)
+)
+
+// Register makes a formatter available by the format name.
+func Register(name string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	if _, dup := factories[name]; dup {
+		panic("formatter: Register called twice for " + name)
+	}
+	factories[name] = factory
+}
+
+// New creates a formatter by the "format" setting.
+// The raw formatter is used if the setting is not set.
+func New(config gigo.PluginConfig) (Formatter, error) {
+	name := config.String("format", "raw")
+	factoriesMu.RLock()
+	factory, ok := factories[name]
+	factoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("formatter: unknown format %s", name)
+	}
+	return factory(config)
+}
+
+func newConfig(config gigo.PluginConfig) Config {
+	return Config{
+		TagKey:     config.String("tag_key", ""),
+		TimeKey:    config.String("time_key", ""),
+		TimeLayout: config.String("time_layout", ""),
+	}
+}
@@ -0,0 +1,116 @@
+package formatter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vmihailenco/msgpack"
+
+	"github.com/najeira/gigo"
+)
+
+func newRecord() *gigo.Record {
+	r := gigo.NewRecord("app", []byte("raw line"))
+	r.Time = time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)
+	r.Set("b", "x\ty")
+	r.Set("a", 1)
+	return r
+}
+
+func format(t *testing.T, f Formatter, r *gigo.Record) string {
+	data, err := f.Format(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRaw(t *testing.T) {
+	if s := format(t, NewRaw(RawConfig{}), newRecord()); s != "raw line\n" {
+		t.Errorf("invalid format: %q", s)
+	}
+	if s := format(t, NewRaw(RawConfig{Key: "a"}), newRecord()); s != "1\n" {
+		t.Errorf("invalid format: %q", s)
+	}
+
+	// the fields modified by filters are written with the raw bytes
+	r := newRecord()
+	r.Modified = true
+	if s := format(t, NewRaw(RawConfig{}), r); s != `{"a":1,"b":"x\ty","message":"raw line"}`+"\n" {
+		t.Errorf("invalid format: %q", s)
+	}
+	r.Set("msg", "parsed")
+	if s := format(t, NewRaw(RawConfig{MessageKey: "msg"}), r); s != `{"a":1,"b":"x\ty","msg":"parsed"}`+"\n" {
+		t.Errorf("invalid format: %q", s)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	f := NewJSON(Config{TagKey: "tag", TimeKey: "time", TimeLayout: "unix"})
+	s := format(t, f, newRecord())
+	if s != `{"a":1,"b":"x\ty","tag":"app","time":1451703845}`+"\n" {
+		t.Errorf("invalid format: %q", s)
+	}
+}
+
+func TestLTSV(t *testing.T) {
+	f := NewLTSV(Config{TimeKey: "time"})
+	s := format(t, f, newRecord())
+	if s != "a:1\tb:x\\ty\ttime:2016-01-02T03:04:05Z\n" {
+		t.Errorf("invalid format: %q", s)
+	}
+}
+
+func TestCSV(t *testing.T) {
+	f, err := NewCSV(CSVConfig{Columns: []string{"b", "none", "a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := format(t, f, newRecord()); s != "x\ty,,1\n" {
+		t.Errorf("invalid format: %q", s)
+	}
+
+	if _, err := NewCSV(CSVConfig{}); err == nil {
+		t.Error("no error without columns")
+	}
+}
+
+func TestMsgpack(t *testing.T) {
+	data, err := NewMsgpack(Config{}).Format(newRecord())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]interface{}
+	if err := msgpack.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m["b"] != "x\ty" {
+		t.Errorf("invalid value: %v", m)
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	f, err := NewTemplate(TemplateConfig{
+		Template:   `{{.Tag}} {{index .Fields "b"}}`,
+		AddNewline: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := format(t, f, newRecord()); s != "app x\ty\n" {
+		t.Errorf("invalid format: %q", s)
+	}
+}
+
+func TestNew(t *testing.T) {
+	f, err := New(gigo.PluginConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := format(t, f, newRecord()); s != "raw line\n" {
+		t.Errorf("invalid format: %q", s)
+	}
+	if _, err := New(gigo.PluginConfig{"format": "unknown"}); err == nil {
+		t.Error("no error for unknown format")
+	}
+}
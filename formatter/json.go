@@ -0,0 +1,32 @@
+package formatter
+
+import (
+	"encoding/json"
+
+	"github.com/najeira/gigo"
+)
+
+func init() {
+	Register("json", newJSONFormatter)
+}
+
+// NewJSON returns a formatter writing the fields as a JSON line.
+func NewJSON(config Config) Formatter {
+	return &jsonFormatter{config: config}
+}
+
+type jsonFormatter struct {
+	config Config
+}
+
+func newJSONFormatter(config gigo.PluginConfig) (Formatter, error) {
+	return NewJSON(newConfig(config)), nil
+}
+
+func (f *jsonFormatter) Format(record *gigo.Record) ([]byte, error) {
+	data, err := json.Marshal(f.config.fields(record))
+	if err != nil {
+		return nil, err
+	}
+	return append(data, lineEnd...), nil
+}
@@ -0,0 +1,65 @@
+package formatter
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/najeira/gigo"
+)
+
+var (
+	ltsvEscaper = strings.NewReplacer("\t", `\t`, "\n", `\n`, "\r", `\r`)
+)
+
+func init() {
+	Register("ltsv", newLTSVFormatter)
+}
+
+// NewLTSV returns a formatter writing the fields as Labeled Tab-separated
+// Values. Labels are sorted, and tabs and newlines in values are escaped.
+func NewLTSV(config Config) Formatter {
+	return &ltsv{config: config}
+}
+
+type ltsv struct {
+	config Config
+}
+
+func newLTSVFormatter(config gigo.PluginConfig) (Formatter, error) {
+	return NewLTSV(newConfig(config)), nil
+}
+
+func (f *ltsv) Format(record *gigo.Record) ([]byte, error) {
+	fields := f.config.fields(record)
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	for i, key := range keys {
+		if i > 0 {
+			buf.WriteByte('\t')
+		}
+		buf.WriteString(key)
+		buf.WriteByte(':')
+		buf.WriteString(ltsvEscaper.Replace(toString(fields[key])))
+	}
+	buf.Write(lineEnd)
+	return buf.Bytes(), nil
+}
+
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+	return fmt.Sprint(value)
+}
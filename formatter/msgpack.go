@@ -0,0 +1,28 @@
+package formatter
+
+import (
+	"github.com/vmihailenco/msgpack"
+
+	"github.com/najeira/gigo"
+)
+
+func init() {
+	Register("msgpack", newMsgpackFormatter)
+}
+
+// NewMsgpack returns a formatter writing the fields as a MessagePack map.
+func NewMsgpack(config Config) Formatter {
+	return &msgpackFormatter{config: config}
+}
+
+type msgpackFormatter struct {
+	config Config
+}
+
+func newMsgpackFormatter(config gigo.PluginConfig) (Formatter, error) {
+	return NewMsgpack(newConfig(config)), nil
+}
+
+func (f *msgpackFormatter) Format(record *gigo.Record) ([]byte, error) {
+	return msgpack.Marshal(f.config.fields(record))
+}
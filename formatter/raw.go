@@ -0,0 +1,89 @@
+package formatter
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/najeira/gigo"
+)
+
+func init() {
+	Register("raw", newRawFormatter)
+}
+
+const (
+	defaultMessageKey = "message"
+)
+
+type RawConfig struct {
+	// Key is the field to write. The raw bytes of the record are written
+	// if empty, or the fields as JSON if the record has no raw bytes or
+	// was modified by filters.
+	Key string
+
+	// MessageKey is the field of the raw bytes in the JSON of a record
+	// modified by filters, unless the record has the field.
+	// Default is "message".
+	MessageKey string
+}
+
+// NewRaw returns a formatter writing a single value as a line.
+func NewRaw(config RawConfig) Formatter {
+	f := &raw{key: config.Key, messageKey: config.MessageKey}
+	if f.messageKey == "" {
+		f.messageKey = defaultMessageKey
+	}
+	return f
+}
+
+type raw struct {
+	key        string
+	messageKey string
+}
+
+func newRawFormatter(config gigo.PluginConfig) (Formatter, error) {
+	return NewRaw(RawConfig{
+		Key:        config.String("key", ""),
+		MessageKey: config.String("message_key", ""),
+	}), nil
+}
+
+func (f *raw) Format(record *gigo.Record) ([]byte, error) {
+	var data []byte
+	var err error
+	switch {
+	case f.key != "":
+		switch v := record.Fields[f.key].(type) {
+		case nil:
+		case string:
+			data = []byte(v)
+		case []byte:
+			data = v
+		default:
+			data = []byte(fmt.Sprint(v))
+		}
+	case record.Modified && record.Raw != nil:
+		data, err = f.modified(record)
+	default:
+		data, err = record.Bytes()
+	}
+	if err != nil {
+		return nil, err
+	}
+	line := make([]byte, 0, len(data)+len(lineEnd))
+	line = append(line, data...)
+	return append(line, lineEnd...), nil
+}
+
+// modified returns the fields of the record modified by filters as JSON,
+// with the raw bytes as the message key.
+func (f *raw) modified(record *gigo.Record) ([]byte, error) {
+	fields := make(map[string]interface{}, len(record.Fields)+1)
+	for key, value := range record.Fields {
+		fields[key] = value
+	}
+	if _, ok := fields[f.messageKey]; !ok {
+		fields[f.messageKey] = string(record.Raw)
+	}
+	return json.Marshal(fields)
+}
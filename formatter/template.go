@@ -0,0 +1,56 @@
+package formatter
+
+import (
+	"bytes"
+	"text/template"
+
+	"github.com/najeira/gigo"
+)
+
+func init() {
+	Register("template", newTemplateFormatter)
+}
+
+type TemplateConfig struct {
+	// Template is executed with the record, such as
+	// `{{.Time.Format "15:04:05"}} {{.Tag}} {{index .Fields "message"}}`.
+	Template string
+
+	// AddNewline appends a newline to the result.
+	AddNewline bool
+}
+
+// NewTemplate returns a formatter executing a text/template.
+func NewTemplate(config TemplateConfig) (Formatter, error) {
+	tmpl, err := template.New("formatter").Parse(config.Template)
+	if err != nil {
+		return nil, err
+	}
+	return &templateFormatter{tmpl: tmpl, addNewline: config.AddNewline}, nil
+}
+
+type templateFormatter struct {
+	tmpl       *template.Template
+	addNewline bool
+}
+
+func newTemplateFormatter(config gigo.PluginConfig) (Formatter, error) {
+	if err := config.Require("template"); err != nil {
+		return nil, err
+	}
+	return NewTemplate(TemplateConfig{
+		Template:   config.String("template", ""),
+		AddNewline: config.Bool("add_newline", true),
+	})
+}
+
+func (f *templateFormatter) Format(record *gigo.Record) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := f.tmpl.Execute(&buf, record); err != nil {
+		return nil, err
+	}
+	if f.addNewline {
+		buf.Write(lineEnd)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,397 @@
+// Package forward implements the messages of the Fluentd Forward
+// protocol v1, used by in_forward and out_fluent.
+package forward
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/vmihailenco/msgpack"
+	"github.com/vmihailenco/msgpack/codes"
+)
+
+var (
+	_ msgpack.Marshaler   = (*EventTime)(nil)
+	_ msgpack.Unmarshaler = (*EventTime)(nil)
+
+	errInvalidMessage = errors.New("forward: invalid message")
+	errInvalidEntry   = errors.New("forward: invalid entry")
+
+	// ErrTooLarge is returned for the entries decompressed over the limit.
+	ErrTooLarge = errors.New("forward: message too large")
+)
+
+const (
+	eventTimeExt = 0
+
+	// maxPrealloc limits the entries allocated by the count sent.
+	maxPrealloc = 1024
+
+	// CompressedGzip is the "compressed" option of CompressedPackedForward.
+	CompressedGzip = "gzip"
+)
+
+func init() {
+	msgpack.RegisterExt(eventTimeExt, (*EventTime)(nil))
+}
+
+// EventTime is the time with nanoseconds encoded as the ext type 0.
+type EventTime struct {
+	time.Time
+}
+
+func (t *EventTime) MarshalMsgpack() ([]byte, error) {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint32(b, uint32(t.Unix()))
+	binary.BigEndian.PutUint32(b[4:], uint32(t.Nanosecond()))
+	return b, nil
+}
+
+func (t *EventTime) UnmarshalMsgpack(b []byte) error {
+	if len(b) != 8 {
+		return fmt.Errorf("forward: invalid EventTime length %d", len(b))
+	}
+	sec := binary.BigEndian.Uint32(b)
+	nsec := binary.BigEndian.Uint32(b[4:])
+	t.Time = time.Unix(int64(sec), int64(nsec))
+	return nil
+}
+
+// Entry is an event of a message.
+type Entry struct {
+	Time   time.Time
+	Record map[string]interface{}
+}
+
+// Option is the option of a message.
+type Option struct {
+	// Size is the number of the entries.
+	Size int
+
+	// Chunk requests the ack of the message if not empty.
+	Chunk string
+
+	// Compressed is CompressedGzip for CompressedPackedForward.
+	Compressed string
+}
+
+// Message is a message of any mode, which has the entries of a tag.
+type Message struct {
+	Tag     string
+	Entries []Entry
+	Option  Option
+}
+
+// Decode reads a message of Message, Forward, PackedForward or
+// CompressedPackedForward mode. The entries of CompressedPackedForward
+// over maxSize bytes decompressed are ErrTooLarge. No limit if 0.
+func Decode(dec *msgpack.Decoder, maxSize int) (*Message, error) {
+	n, err := dec.DecodeArrayLen()
+	if err != nil {
+		return nil, err
+	}
+	if n < 2 || n > 4 {
+		return nil, errInvalidMessage
+	}
+	m := &Message{}
+	if m.Tag, err = dec.DecodeString(); err != nil {
+		return nil, err
+	}
+
+	c, err := dec.PeekCode()
+	if err != nil {
+		return nil, err
+	}
+	rest := n - 2
+	switch {
+	case codes.IsFixedArray(c) || c == codes.Array16 || c == codes.Array32:
+		err = m.decodeForward(dec)
+	case codes.IsBin(c) || codes.IsString(c):
+		err = m.decodePacked(dec, rest > 0, maxSize)
+		rest = 0
+	default:
+		// Message mode has the record after the time.
+		if n < 3 {
+			return nil, errInvalidMessage
+		}
+		err = m.decodeMessage(dec)
+		rest--
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if rest > 0 {
+		if m.Option, err = decodeOption(dec); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func (m *Message) decodeMessage(dec *msgpack.Decoder) error {
+	t, err := decodeTime(dec)
+	if err != nil {
+		return err
+	}
+	record, err := decodeRecord(dec)
+	if err != nil {
+		return err
+	}
+	m.Entries = []Entry{{Time: t, Record: record}}
+	return nil
+}
+
+func (m *Message) decodeForward(dec *msgpack.Decoder) error {
+	n, err := dec.DecodeArrayLen()
+	if err != nil {
+		return err
+	}
+	if n > maxPrealloc {
+		m.Entries = make([]Entry, 0, maxPrealloc)
+	} else {
+		m.Entries = make([]Entry, 0, n)
+	}
+	for i := 0; i < n; i++ {
+		entry, err := decodeEntry(dec)
+		if err != nil {
+			return err
+		}
+		m.Entries = append(m.Entries, entry)
+	}
+	return nil
+}
+
+// decodePacked reads the entries packed in bytes, and the option if any
+// to know if they are compressed.
+func (m *Message) decodePacked(dec *msgpack.Decoder, hasOption bool, maxSize int) error {
+	data, err := dec.DecodeBytes()
+	if err != nil {
+		return err
+	}
+	if hasOption {
+		if m.Option, err = decodeOption(dec); err != nil {
+			return err
+		}
+	}
+
+	switch m.Option.Compressed {
+	case "":
+	case CompressedGzip:
+		if data, err = gunzip(data, maxSize); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("forward: unknown compression %s", m.Option.Compressed)
+	}
+
+	entries := msgpack.NewDecoder(bytes.NewReader(data))
+	for {
+		entry, err := decodeEntry(entries)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		m.Entries = append(m.Entries, entry)
+	}
+}
+
+// gunzip decompresses data, which may be concatenated gzip streams,
+// up to maxSize bytes.
+func gunzip(data []byte, maxSize int) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	if maxSize <= 0 {
+		return ioutil.ReadAll(gz)
+	}
+	data, err = ioutil.ReadAll(io.LimitReader(gz, int64(maxSize)+1))
+	if err != nil {
+		return nil, err
+	} else if len(data) > maxSize {
+		return nil, ErrTooLarge
+	}
+	return data, nil
+}
+
+func decodeEntry(dec *msgpack.Decoder) (Entry, error) {
+	n, err := dec.DecodeArrayLen()
+	if err != nil {
+		return Entry{}, err
+	}
+	if n != 2 {
+		return Entry{}, errInvalidEntry
+	}
+	t, err := decodeTime(dec)
+	if err != nil {
+		return Entry{}, err
+	}
+	record, err := decodeRecord(dec)
+	if err != nil {
+		return Entry{}, err
+	}
+	return Entry{Time: t, Record: record}, nil
+}
+
+// decodeTime reads an EventTime, or an integer or a float of seconds.
+func decodeTime(dec *msgpack.Decoder) (time.Time, error) {
+	v, err := dec.DecodeInterfaceLoose()
+	if err != nil {
+		return time.Time{}, err
+	}
+	switch t := v.(type) {
+	case *EventTime:
+		return t.Time, nil
+	case int64:
+		return time.Unix(t, 0), nil
+	case uint64:
+		return time.Unix(int64(t), 0), nil
+	case float64:
+		sec := int64(t)
+		return time.Unix(sec, int64((t-float64(sec))*1e9)), nil
+	}
+	return time.Time{}, fmt.Errorf("forward: invalid time %v", v)
+}
+
+func decodeRecord(dec *msgpack.Decoder) (map[string]interface{}, error) {
+	v, err := dec.DecodeInterface()
+	if err != nil {
+		return nil, err
+	}
+	return stringMap(v)
+}
+
+func decodeOption(dec *msgpack.Decoder) (Option, error) {
+	var o Option
+	v, err := dec.DecodeInterface()
+	if err != nil || v == nil {
+		return o, err
+	}
+	m, err := stringMap(v)
+	if err != nil {
+		return o, err
+	}
+	o.Chunk = toString(m["chunk"])
+	o.Compressed = toString(m["compressed"])
+	o.Size = int(toInt64(m["size"]))
+	return o, nil
+}
+
+// stringMap returns the map of v with the keys as strings.
+func stringMap(v interface{}) (map[string]interface{}, error) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m, nil
+	case map[interface{}]interface{}:
+		sm := make(map[string]interface{}, len(m))
+		for key, value := range m {
+			switch k := key.(type) {
+			case string:
+				sm[k] = value
+			case []byte:
+				sm[string(k)] = value
+			default:
+				return nil, fmt.Errorf("forward: invalid key %v", key)
+			}
+		}
+		return sm, nil
+	}
+	return nil, fmt.Errorf("forward: invalid record %T", v)
+}
+
+func toString(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case []byte:
+		return string(s)
+	}
+	return ""
+}
+
+func toInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case int8:
+		return int64(n)
+	case int16:
+		return int64(n)
+	case int32:
+		return int64(n)
+	case int64:
+		return n
+	case uint8:
+		return int64(n)
+	case uint16:
+		return int64(n)
+	case uint32:
+		return int64(n)
+	case uint64:
+		return int64(n)
+	}
+	return 0
+}
+
+// Ack is the response to a message with the chunk option.
+type Ack struct {
+	Ack string `msgpack:"ack"`
+}
+
+// Digest returns the hex SHA-512 of the handshake, used for the shared
+// key of PING with the client hostname and PONG with the server hostname.
+func Digest(salt, hostname, nonce, sharedKey []byte) string {
+	h := sha512.New()
+	h.Write(salt)
+	h.Write(hostname)
+	h.Write(nonce)
+	h.Write(sharedKey)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// EncodeEntry writes an entry of PackedForward mode.
+func EncodeEntry(enc *msgpack.Encoder, t time.Time, record interface{}) error {
+	return enc.Encode([]interface{}{&EventTime{t}, record})
+}
+
+// EncodePacked writes a PackedForward message of the entries written by
+// EncodeEntry. The entries are compressed if option.Compressed is
+// CompressedGzip, as CompressedPackedForward mode.
+func EncodePacked(enc *msgpack.Encoder, tag string, entries []byte, option Option) error {
+	switch option.Compressed {
+	case "":
+	case CompressedGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(entries); err != nil {
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		entries = buf.Bytes()
+	default:
+		return fmt.Errorf("forward: unknown compression %s", option.Compressed)
+	}
+
+	o := make(map[string]interface{}, 3)
+	if option.Size > 0 {
+		o["size"] = option.Size
+	}
+	if option.Chunk != "" {
+		o["chunk"] = option.Chunk
+	}
+	if option.Compressed != "" {
+		o["compressed"] = option.Compressed
+	}
+	return enc.Encode([]interface{}{tag, entries, o})
+}
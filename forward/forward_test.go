@@ -0,0 +1,150 @@
+package forward
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+	"time"
+
+	"github.com/vmihailenco/msgpack"
+)
+
+func TestDecode(t *testing.T) {
+	t1 := time.Unix(1500000000, 123456789)
+	t2 := time.Unix(1500000001, 0)
+	entry := func(tm time.Time, msg string) []interface{} {
+		return []interface{}{&EventTime{tm}, map[string]interface{}{"msg": msg}}
+	}
+
+	var packed bytes.Buffer
+	enc := msgpack.NewEncoder(&packed)
+	enc.Encode(entry(t1, "a"))
+	enc.Encode([]interface{}{t2.Unix(), map[string]interface{}{"msg": "b"}})
+
+	// concatenated gzip streams
+	var compressed bytes.Buffer
+	for _, e := range [][]interface{}{entry(t1, "a"), entry(t2, "b")} {
+		data, err := msgpack.Marshal(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := gzip.NewWriter(&compressed)
+		w.Write(data)
+		w.Close()
+	}
+
+	tests := []struct {
+		name    string
+		message []interface{}
+		msgs    []string
+		times   []time.Time
+		option  Option
+	}{
+		{
+			"message",
+			[]interface{}{"tag", t2.Unix(), map[string]interface{}{"msg": "a"}},
+			[]string{"a"}, []time.Time{t2}, Option{},
+		},
+		{
+			"message with option",
+			[]interface{}{"tag", &EventTime{t1}, map[string]interface{}{"msg": "a"},
+				map[string]interface{}{"chunk": "c1"}},
+			[]string{"a"}, []time.Time{t1}, Option{Chunk: "c1"},
+		},
+		{
+			"forward",
+			[]interface{}{"tag", []interface{}{entry(t1, "a"), entry(t2, "b")},
+				map[string]interface{}{"size": 2, "chunk": "c2"}},
+			[]string{"a", "b"}, []time.Time{t1, t2}, Option{Size: 2, Chunk: "c2"},
+		},
+		{
+			"packed forward",
+			[]interface{}{"tag", packed.Bytes()},
+			[]string{"a", "b"}, []time.Time{t1, t2}, Option{},
+		},
+		{
+			"compressed packed forward",
+			[]interface{}{"tag", compressed.Bytes(),
+				map[string]interface{}{"compressed": "gzip"}},
+			[]string{"a", "b"}, []time.Time{t1, t2}, Option{Compressed: "gzip"},
+		},
+	}
+
+	for _, test := range tests {
+		data, err := msgpack.Marshal(test.message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := Decode(msgpack.NewDecoder(bytes.NewReader(data)), 0)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if m.Tag != "tag" {
+			t.Errorf("%s: invalid tag %s", test.name, m.Tag)
+		}
+		if m.Option != test.option {
+			t.Errorf("%s: invalid option %v", test.name, m.Option)
+		}
+		if len(m.Entries) != len(test.msgs) {
+			t.Errorf("%s: invalid entries %v", test.name, m.Entries)
+			continue
+		}
+		for i, e := range m.Entries {
+			if e.Record["msg"] != test.msgs[i] {
+				t.Errorf("%s: invalid record %v", test.name, e.Record)
+			}
+			if !e.Time.Equal(test.times[i]) {
+				t.Errorf("%s: invalid time %s", test.name, e.Time)
+			}
+		}
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	for _, message := range []interface{}{
+		[]interface{}{"tag"},
+		[]interface{}{"tag", 1},
+		[]interface{}{"tag", 1, "not a record"},
+		[]interface{}{"tag", []interface{}{[]interface{}{1}}},
+		[]interface{}{"tag", []byte{0xc1}},
+		map[string]interface{}{"tag": "tag"},
+	} {
+		data, err := msgpack.Marshal(message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Decode(msgpack.NewDecoder(bytes.NewReader(data)), 0); err == nil {
+			t.Errorf("%v: no error", message)
+		}
+	}
+}
+
+func TestDecodeLimit(t *testing.T) {
+	// a huge count of entries without the entries
+	data := []byte{0x92, 0xa3, 't', 'a', 'g', 0xdd, 0xff, 0xff, 0xff, 0xf0}
+	if _, err := Decode(msgpack.NewDecoder(bytes.NewReader(data)), 0); err == nil {
+		t.Error("no error")
+	}
+
+	var entries bytes.Buffer
+	enc := msgpack.NewEncoder(&entries)
+	for i := 0; i < 100; i++ {
+		EncodeEntry(enc, time.Now(), map[string]interface{}{"msg": "0123456789"})
+	}
+	var buf bytes.Buffer
+	option := Option{Compressed: CompressedGzip}
+	if err := EncodePacked(msgpack.NewEncoder(&buf), "tag", entries.Bytes(), option); err != nil {
+		t.Fatal(err)
+	}
+	data = buf.Bytes()
+	if _, err := Decode(msgpack.NewDecoder(bytes.NewReader(data)), entries.Len()-1); err != ErrTooLarge {
+		t.Errorf("invalid error %v", err)
+	}
+	m, err := Decode(msgpack.NewDecoder(bytes.NewReader(data)), entries.Len())
+	if err != nil {
+		t.Error(err)
+	} else if len(m.Entries) != 100 {
+		t.Errorf("invalid entries %d", len(m.Entries))
+	}
+}
@@ -0,0 +1,124 @@
+package forward
+
+import (
+	"errors"
+
+	"github.com/vmihailenco/msgpack"
+)
+
+var (
+	errInvalidHelo = errors.New("forward: invalid HELO")
+	errInvalidPing = errors.New("forward: invalid PING")
+	errInvalidPong = errors.New("forward: invalid PONG")
+)
+
+// Helo is sent by the server to start the handshake.
+type Helo struct {
+	Nonce []byte
+
+	// Auth is the salt for the user authentication, or empty.
+	Auth []byte
+
+	Keepalive bool
+}
+
+func (h *Helo) Encode(enc *msgpack.Encoder) error {
+	return enc.Encode([]interface{}{
+		"HELO",
+		map[string]interface{}{
+			"nonce":     h.Nonce,
+			"auth":      h.Auth,
+			"keepalive": h.Keepalive,
+		},
+	})
+}
+
+func DecodeHelo(dec *msgpack.Decoder) (*Helo, error) {
+	v, err := dec.DecodeInterface()
+	if err != nil {
+		return nil, err
+	}
+	a, ok := v.([]interface{})
+	if !ok || len(a) != 2 || toString(a[0]) != "HELO" {
+		return nil, errInvalidHelo
+	}
+	options, err := stringMap(a[1])
+	if err != nil {
+		return nil, errInvalidHelo
+	}
+	keepalive, _ := options["keepalive"].(bool)
+	return &Helo{
+		Nonce:     []byte(toString(options["nonce"])),
+		Auth:      []byte(toString(options["auth"])),
+		Keepalive: keepalive,
+	}, nil
+}
+
+// Ping is sent by the client to authenticate.
+type Ping struct {
+	Hostname string
+	Salt     []byte
+
+	// Digest is the Digest of Salt, Hostname, the nonce and the shared key.
+	Digest string
+
+	Username string
+	Password string
+}
+
+func (p *Ping) Encode(enc *msgpack.Encoder) error {
+	return enc.Encode([]interface{}{
+		"PING", p.Hostname, p.Salt, p.Digest, p.Username, p.Password,
+	})
+}
+
+func DecodePing(dec *msgpack.Decoder) (*Ping, error) {
+	v, err := dec.DecodeInterface()
+	if err != nil {
+		return nil, err
+	}
+	a, ok := v.([]interface{})
+	if !ok || len(a) != 6 || toString(a[0]) != "PING" {
+		return nil, errInvalidPing
+	}
+	return &Ping{
+		Hostname: toString(a[1]),
+		Salt:     []byte(toString(a[2])),
+		Digest:   toString(a[3]),
+		Username: toString(a[4]),
+		Password: toString(a[5]),
+	}, nil
+}
+
+// Pong is the result of the authentication sent by the server.
+type Pong struct {
+	OK       bool
+	Reason   string
+	Hostname string
+
+	// Digest is the Digest of the salt of PING, Hostname, the nonce
+	// and the shared key.
+	Digest string
+}
+
+func (p *Pong) Encode(enc *msgpack.Encoder) error {
+	return enc.Encode([]interface{}{"PONG", p.OK, p.Reason, p.Hostname, p.Digest})
+}
+
+func DecodePong(dec *msgpack.Decoder) (*Pong, error) {
+	v, err := dec.DecodeInterface()
+	if err != nil {
+		return nil, err
+	}
+	a, ok := v.([]interface{})
+	if !ok || len(a) != 5 || toString(a[0]) != "PONG" {
+		return nil, errInvalidPong
+	}
+	ok, _ = a[1].(bool)
+	return &Pong{
+		OK:       ok,
+		Reason:   toString(a[2]),
+		Hostname: toString(a[3]),
+		Digest:   toString(a[4]),
+	}, nil
+}
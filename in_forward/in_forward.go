@@ -0,0 +1,261 @@
+package in_forward
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"github.com/vmihailenco/msgpack"
+
+	"github.com/najeira/gigo"
+	"github.com/najeira/gigo/forward"
+	"github.com/najeira/gigo/in_net"
+)
+
+var (
+	_ gigo.Input    = (*Reader)(nil)
+	_ gigo.Reloader = (*Reader)(nil)
+
+	errSharedKeyMismatch = errors.New("in_forward: shared key mismatch")
+)
+
+const (
+	nonceSize = 16
+
+	defaultMaxMessageSize = 16 * 1024 * 1024
+	defaultReadTimeout    = time.Second * 30
+)
+
+func init() {
+	gigo.RegisterInput("forward", newInput)
+}
+
+type Config struct {
+	// Net is "tcp" or "unix" and so on. Default is "tcp".
+	Net    string
+	Addr   string
+	Logger gigo.Logger
+
+	// SharedKey requires the clients to authenticate by the handshake
+	// if not empty.
+	SharedKey string
+
+	// Hostname is sent to the clients in the handshake.
+	// Default is os.Hostname.
+	Hostname string
+
+	// TLS serves TLS if not nil.
+	TLS *in_net.TLSConfig
+
+	// MaxMessageSize closes the connection sending a message over
+	// the bytes, also decompressed. Default is 16MB.
+	MaxMessageSize int
+
+	// ReadTimeout closes the connection not sending the rest of
+	// the handshake or a message for the duration. Default is 30s.
+	ReadTimeout time.Duration
+
+	// MaxConnections, IdleTimeout and CloseTimeout are the ones of in_net.
+	MaxConnections int
+	IdleTimeout    time.Duration
+	CloseTimeout   time.Duration
+}
+
+// Reader receives the messages of the Forward protocol v1 in any mode,
+// and emits the entries as records with the tag and the time of them.
+// It responds the ack to a message with the chunk option after all
+// its entries are emitted.
+type Reader struct {
+	net       *in_net.Reader
+	logger    gigo.Logger
+	sharedKey []byte
+	hostname  string
+	emitter   gigo.Emitter
+
+	maxMessageSize int
+	readTimeout    time.Duration
+}
+
+func New(config Config) *Reader {
+	r := &Reader{
+		logger:    gigo.EnsureLogger(config.Logger),
+		sharedKey: []byte(config.SharedKey),
+		hostname:  config.Hostname,
+
+		maxMessageSize: config.MaxMessageSize,
+		readTimeout:    config.ReadTimeout,
+	}
+	if r.maxMessageSize <= 0 {
+		r.maxMessageSize = defaultMaxMessageSize
+	}
+	if r.readTimeout <= 0 {
+		r.readTimeout = defaultReadTimeout
+	}
+	if r.hostname == "" {
+		r.hostname, _ = os.Hostname()
+	}
+	network := config.Net
+	if network == "" {
+		network = "tcp"
+	}
+	r.net = in_net.New(in_net.Config{
+		Net:            network,
+		Addr:           config.Addr,
+		Handler:        r.handle,
+		Logger:         r.logger,
+		TLS:            config.TLS,
+		MaxConnections: config.MaxConnections,
+		IdleTimeout:    config.IdleTimeout,
+		CloseTimeout:   config.CloseTimeout,
+	})
+	return r
+}
+
+func newInput(config gigo.PluginConfig) (gigo.Input, error) {
+	if err := config.Require("addr"); err != nil {
+		return nil, err
+	}
+	idleTimeout, err := config.Duration("idle_timeout", 0)
+	if err != nil {
+		return nil, err
+	}
+	closeTimeout, err := config.Duration("close_timeout", 0)
+	if err != nil {
+		return nil, err
+	}
+	readTimeout, err := config.Duration("read_timeout", defaultReadTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return New(Config{
+		Logger:         config.Logger(),
+		Net:            config.String("net", "tcp"),
+		Addr:           config.String("addr", ""),
+		SharedKey:      config.String("shared_key", ""),
+		Hostname:       config.String("self_hostname", ""),
+		TLS:            in_net.TLSConfigFrom(config),
+		MaxMessageSize: int(config.Int("max_message_size", defaultMaxMessageSize)),
+		ReadTimeout:    readTimeout,
+		MaxConnections: int(config.Int("max_connections", 0)),
+		IdleTimeout:    idleTimeout,
+		CloseTimeout:   closeTimeout,
+	}), nil
+}
+
+// Start listens and emits the entries received to e until Stop.
+func (r *Reader) Start(e gigo.Emitter) error {
+	r.emitter = e
+	return r.net.Start(e)
+}
+
+func (r *Reader) handle(conn net.Conn) {
+	remoteAddr := conn.RemoteAddr().String()
+	br := bufio.NewReader(conn)
+	mr := &messageReader{r: br}
+	dec := msgpack.NewDecoder(mr)
+	enc := msgpack.NewEncoder(conn)
+
+	if len(r.sharedKey) > 0 {
+		conn.SetReadDeadline(time.Now().Add(r.readTimeout))
+		mr.n = r.maxMessageSize
+		if err := r.handshake(dec, enc); err != nil {
+			r.logger.Warnf("in_forward: handshake error %s from %s", err, remoteAddr)
+			return
+		}
+	}
+
+	for {
+		// wait for a message as long as the connection is not idle,
+		// then read it by the timeout and the size
+		conn.SetReadDeadline(time.Time{})
+		if _, err := br.Peek(1); err == io.EOF {
+			return
+		} else if err != nil {
+			r.logger.Infof("in_forward: read error %s from %s", err, remoteAddr)
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(r.readTimeout))
+		mr.n = r.maxMessageSize
+
+		m, err := forward.Decode(dec, r.maxMessageSize)
+		if err == io.EOF {
+			return
+		} else if err != nil {
+			r.logger.Infof("in_forward: read error %s from %s", err, remoteAddr)
+			return
+		}
+		if !r.emit(m) {
+			// the client sends the chunk again without the ack
+			continue
+		}
+		if m.Option.Chunk != "" {
+			if err := enc.Encode(&forward.Ack{Ack: m.Option.Chunk}); err != nil {
+				r.logger.Infof("in_forward: ack error %s to %s", err, remoteAddr)
+				return
+			}
+		}
+	}
+}
+
+func (r *Reader) emit(m *forward.Message) bool {
+	for _, entry := range m.Entries {
+		record := &gigo.Record{Tag: m.Tag, Time: entry.Time, Fields: entry.Record}
+		if err := r.emitter.Emit(record); err != nil {
+			r.logger.Warnf("in_forward: emit error %s", err)
+			return false
+		}
+	}
+	return true
+}
+
+// handshake sends HELO and authenticates PING by the shared key.
+func (r *Reader) handshake(dec *msgpack.Decoder, enc *msgpack.Encoder) error {
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	helo := forward.Helo{Nonce: nonce, Auth: []byte{}, Keepalive: true}
+	if err := helo.Encode(enc); err != nil {
+		return err
+	}
+
+	ping, err := forward.DecodePing(dec)
+	if err != nil {
+		return err
+	}
+	digest := forward.Digest(ping.Salt, []byte(ping.Hostname), nonce, r.sharedKey)
+	if !hmac.Equal([]byte(ping.Digest), []byte(digest)) {
+		pong := forward.Pong{Reason: "shared_key mismatch", Hostname: r.hostname}
+		pong.Encode(enc)
+		return errSharedKeyMismatch
+	}
+
+	pong := forward.Pong{
+		OK:       true,
+		Hostname: r.hostname,
+		Digest:   forward.Digest(ping.Salt, []byte(r.hostname), nonce, r.sharedKey),
+	}
+	return pong.Encode(enc)
+}
+
+func (r *Reader) Stop() error {
+	return r.net.Stop()
+}
+
+func (r *Reader) Reload() error {
+	return r.net.Reload()
+}
+
+// Addr returns the address listened, or nil if not started.
+func (r *Reader) Addr() net.Addr {
+	return r.net.Addr()
+}
+
+func (r *Reader) Health() error {
+	return r.net.Health()
+}
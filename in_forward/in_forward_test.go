@@ -0,0 +1,266 @@
+package in_forward
+
+import (
+	"bufio"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vmihailenco/msgpack"
+
+	"github.com/najeira/gigo"
+	"github.com/najeira/gigo/forward"
+	"github.com/najeira/gigo/testutil"
+)
+
+func TestReader(t *testing.T) {
+	l := testutil.Logger{}
+	var mu sync.Mutex
+	var rets []string
+	emitter := gigo.EmitterFunc(func(record *gigo.Record) error {
+		mu.Lock()
+		defer mu.Unlock()
+		rets = append(rets, record.Tag+":"+record.GetString("msg"))
+		return nil
+	})
+
+	r := New(Config{
+		Logger:    &l,
+		Addr:      "127.0.0.1:0",
+		SharedKey: "secret",
+		Hostname:  "server",
+	})
+	if err := r.Start(emitter); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+	addr := r.net.Addr().String()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second))
+	dec := msgpack.NewDecoder(bufio.NewReader(conn))
+	enc := msgpack.NewEncoder(conn)
+
+	nonce := readHelo(t, dec)
+	salt := []byte("salt")
+	digest := forward.Digest(salt, []byte("client"), nonce, []byte("secret"))
+	if err := enc.Encode([]interface{}{"PING", "client", salt, digest, "", ""}); err != nil {
+		t.Fatal(err)
+	}
+	var pong []interface{}
+	if err := dec.Decode(&pong); err != nil {
+		t.Fatal(err)
+	}
+	if len(pong) != 5 || pong[0] != "PONG" || pong[1] != true || pong[3] != "server" {
+		t.Fatalf("invalid PONG %v", pong)
+	}
+	if pong[4] != forward.Digest(salt, []byte("server"), nonce, []byte("secret")) {
+		t.Errorf("invalid PONG digest %v", pong[4])
+	}
+
+	now := &forward.EventTime{Time: time.Now()}
+	err = enc.Encode([]interface{}{"app.a", now, map[string]interface{}{"msg": "a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = enc.Encode([]interface{}{"app.b", []interface{}{
+		[]interface{}{now, map[string]interface{}{"msg": "b"}},
+		[]interface{}{now, map[string]interface{}{"msg": "c"}},
+	}, map[string]interface{}{"chunk": "chunk1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ack forward.Ack
+	if err := dec.Decode(&ack); err != nil {
+		t.Fatal(err)
+	}
+	if ack.Ack != "chunk1" {
+		t.Errorf("invalid ack %s", ack.Ack)
+	}
+
+	mu.Lock()
+	sort.Strings(rets)
+	if ret := strings.Join(rets, ","); ret != "app.a:a,app.b:b,app.b:c" {
+		t.Errorf("invalid emit %s", ret)
+	}
+	mu.Unlock()
+	if warns := l.Warn.String(); warns != "" {
+		t.Error(warns)
+	}
+}
+
+func TestSharedKeyMismatch(t *testing.T) {
+	l := testutil.Logger{}
+	r := New(Config{Logger: &l, Addr: "127.0.0.1:0", SharedKey: "secret"})
+	if err := r.Start(gigo.EmitterFunc(func(*gigo.Record) error { return nil })); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	conn, err := net.Dial("tcp", r.net.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second))
+	dec := msgpack.NewDecoder(bufio.NewReader(conn))
+	enc := msgpack.NewEncoder(conn)
+
+	nonce := readHelo(t, dec)
+	digest := forward.Digest(nil, []byte("client"), nonce, []byte("wrong"))
+	if err := enc.Encode([]interface{}{"PING", "client", "", digest, "", ""}); err != nil {
+		t.Fatal(err)
+	}
+	var pong []interface{}
+	if err := dec.Decode(&pong); err != nil {
+		t.Fatal(err)
+	}
+	if len(pong) != 5 || pong[1] != false {
+		t.Errorf("invalid PONG %v", pong)
+	}
+	if _, err := dec.DecodeInterface(); err == nil {
+		t.Error("not closed")
+	}
+}
+
+func readHelo(t *testing.T, dec *msgpack.Decoder) []byte {
+	var helo []interface{}
+	if err := dec.Decode(&helo); err != nil {
+		t.Fatal(err)
+	}
+	if len(helo) != 2 || helo[0] != "HELO" {
+		t.Fatalf("invalid HELO %v", helo)
+	}
+	options, _ := helo[1].(map[string]interface{})
+	nonce, _ := options["nonce"].([]byte)
+	if len(nonce) <= 0 {
+		t.Fatalf("invalid nonce %v", helo[1])
+	}
+	return nonce
+}
+
+func TestLimits(t *testing.T) {
+	l := testutil.Logger{}
+	var mu sync.Mutex
+	var rets []string
+	emitter := gigo.EmitterFunc(func(record *gigo.Record) error {
+		mu.Lock()
+		defer mu.Unlock()
+		rets = append(rets, record.GetString("msg"))
+		return nil
+	})
+	r := New(Config{
+		Logger:         &l,
+		Addr:           "127.0.0.1:0",
+		MaxMessageSize: 64,
+		ReadTimeout:    time.Millisecond * 100,
+	})
+	if err := r.Start(emitter); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	dial := func() (net.Conn, *msgpack.Encoder) {
+		conn, err := net.Dial("tcp", r.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.SetDeadline(time.Now().Add(time.Second))
+		return conn, msgpack.NewEncoder(conn)
+	}
+	closed := func(conn net.Conn) bool {
+		_, err := conn.Read(make([]byte, 1))
+		return err != nil && !isTimeout(err)
+	}
+
+	// a message over the size
+	conn, enc := dial()
+	defer conn.Close()
+	enc.Encode([]interface{}{"tag", 0, map[string]interface{}{"msg": "ok"}})
+	enc.Encode([]interface{}{"tag", 0, map[string]interface{}{"msg": strings.Repeat("x", 64)}})
+	if !closed(conn) {
+		t.Error("not closed by the size")
+	}
+
+	// a message not completed
+	conn, _ = dial()
+	defer conn.Close()
+	conn.Write([]byte{0x93, 0xa3, 't', 'a', 'g'})
+	start := time.Now()
+	if !closed(conn) {
+		t.Error("not closed by the timeout")
+	}
+	if d := time.Since(start); d > time.Millisecond*500 {
+		t.Errorf("invalid timeout %s", d)
+	}
+
+	// an idle connection is not closed by the read timeout
+	conn, enc = dial()
+	defer conn.Close()
+	time.Sleep(time.Millisecond * 200)
+	enc.Encode([]interface{}{"tag", 0, map[string]interface{}{"msg": "idle"}})
+	time.Sleep(time.Millisecond * 50)
+
+	mu.Lock()
+	if ret := strings.Join(rets, ","); ret != "ok,idle" {
+		t.Errorf("invalid emit %s", ret)
+	}
+	mu.Unlock()
+}
+
+func TestSlowClient(t *testing.T) {
+	l := testutil.Logger{}
+	r := New(Config{
+		Logger:      &l,
+		Addr:        "127.0.0.1:0",
+		IdleTimeout: time.Second,
+		ReadTimeout: time.Millisecond * 100,
+	})
+	if err := r.Start(gigo.EmitterFunc(func(record *gigo.Record) error {
+		return nil
+	})); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	conn, err := net.Dial("tcp", r.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// a message sent a byte at a time is closed by the read timeout,
+	// not by the idle timeout
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.Read(make([]byte, 1))
+	}()
+	start := time.Now()
+	for _, b := range []byte{0x93, 0xa3, 't', 'a', 'g', 0x00} {
+		if _, err := conn.Write([]byte{b}); err != nil {
+			break
+		}
+		time.Sleep(time.Millisecond * 40)
+	}
+	select {
+	case <-closed:
+	case <-time.After(time.Second * 2):
+		t.Fatal("not closed")
+	}
+	if d := time.Since(start); d > time.Millisecond*500 {
+		t.Errorf("invalid timeout %s", d)
+	}
+}
+
+func isTimeout(err error) bool {
+	ne, ok := err.(net.Error)
+	return ok && ne.Timeout()
+}
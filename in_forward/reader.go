@@ -0,0 +1,48 @@
+package in_forward
+
+import (
+	"bufio"
+	"errors"
+)
+
+var (
+	errMessageTooLarge = errors.New("in_forward: message too large")
+)
+
+// messageReader reads up to n bytes of a message from r. It is an
+// io.ByteScanner so that msgpack.Decoder reads no more than the message.
+type messageReader struct {
+	r *bufio.Reader
+	n int
+}
+
+func (m *messageReader) Read(p []byte) (int, error) {
+	if m.n <= 0 {
+		return 0, errMessageTooLarge
+	}
+	if len(p) > m.n {
+		p = p[:m.n]
+	}
+	n, err := m.r.Read(p)
+	m.n -= n
+	return n, err
+}
+
+func (m *messageReader) ReadByte() (byte, error) {
+	if m.n <= 0 {
+		return 0, errMessageTooLarge
+	}
+	c, err := m.r.ReadByte()
+	if err == nil {
+		m.n--
+	}
+	return c, err
+}
+
+func (m *messageReader) UnreadByte() error {
+	err := m.r.UnreadByte()
+	if err == nil {
+		m.n++
+	}
+	return err
+}
@@ -0,0 +1,221 @@
+package in_http
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/vmihailenco/msgpack"
+
+	"github.com/najeira/gigo"
+)
+
+var (
+	errBodyTooLarge = errors.New("in_http: body too large")
+	errNotObject    = errors.New("in_http: not an object")
+)
+
+// readRecords returns the records of the body, or an error with
+// the status code to respond.
+func (r *Reader) readRecords(tag string, req *http.Request) ([]*gigo.Record, int, error) {
+	if req.ContentLength > r.maxBodySize {
+		return nil, http.StatusRequestEntityTooLarge, errBodyTooLarge
+	}
+
+	decode, err := decoderFor(req.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, http.StatusUnsupportedMediaType, err
+	}
+
+	var body io.Reader = &limitedReader{r: req.Body, n: r.maxBodySize}
+	switch encoding := strings.ToLower(req.Header.Get("Content-Encoding")); encoding {
+	case "", "identity":
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(body)
+		if err == errBodyTooLarge {
+			return nil, http.StatusRequestEntityTooLarge, err
+		} else if err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		defer gz.Close()
+		body = &limitedReader{r: gz, n: r.maxBodySize}
+	default:
+		return nil, http.StatusUnsupportedMediaType,
+			fmt.Errorf("in_http: unsupported encoding %s", encoding)
+	}
+
+	data, err := ioutil.ReadAll(body)
+	if err == errBodyTooLarge {
+		return nil, http.StatusRequestEntityTooLarge, err
+	} else if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	records, err := decode(tag, data)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	return records, http.StatusOK, nil
+}
+
+// limitedReader reads up to n bytes from r, and returns errBodyTooLarge
+// if r has more.
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, errBodyTooLarge
+	}
+	return n, err
+}
+
+type decodeFunc func(tag string, data []byte) ([]*gigo.Record, error)
+
+// decoderFor returns the decoder for the media type. Empty is JSON.
+func decoderFor(contentType string) (decodeFunc, error) {
+	if contentType == "" {
+		return decodeJSON, nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, err
+	}
+	switch mediaType {
+	case "application/json":
+		return decodeJSON, nil
+	case "application/x-ndjson", "application/ndjson", "application/jsonl":
+		return decodeNDJSON, nil
+	case "application/msgpack", "application/x-msgpack":
+		return decodeMsgpack, nil
+	}
+	return nil, fmt.Errorf("in_http: unsupported content type %s", mediaType)
+}
+
+// decodeJSON decodes a JSON object or an array of objects.
+func decodeJSON(tag string, data []byte) ([]*gigo.Record, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) <= 0 || data[0] != '[' {
+		record, err := jsonRecord(tag, data)
+		if err != nil {
+			return nil, err
+		}
+		return []*gigo.Record{record}, nil
+	}
+
+	var objects []json.RawMessage
+	if err := json.Unmarshal(data, &objects); err != nil {
+		return nil, err
+	}
+	records := make([]*gigo.Record, 0, len(objects))
+	for _, object := range objects {
+		record, err := jsonRecord(tag, object)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// decodeNDJSON decodes JSON objects separated by newlines.
+// Blank lines are skipped.
+func decodeNDJSON(tag string, data []byte) ([]*gigo.Record, error) {
+	var records []*gigo.Record
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) <= 0 {
+			continue
+		}
+		record, err := jsonRecord(tag, line)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func jsonRecord(tag string, data []byte) (*gigo.Record, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	if fields == nil {
+		return nil, errNotObject
+	}
+	raw := make([]byte, len(data))
+	copy(raw, data)
+	record := gigo.NewRecord(tag, raw)
+	for key, value := range fields {
+		record.Set(key, value)
+	}
+	return record, nil
+}
+
+// decodeMsgpack decodes a stream of msgpack maps or arrays of maps.
+func decodeMsgpack(tag string, data []byte) ([]*gigo.Record, error) {
+	var records []*gigo.Record
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	for {
+		v, err := dec.DecodeInterface()
+		if err == io.EOF {
+			return records, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		values, ok := v.([]interface{})
+		if !ok {
+			values = []interface{}{v}
+		}
+		for _, value := range values {
+			record, err := msgpackRecord(tag, value)
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, record)
+		}
+	}
+}
+
+func msgpackRecord(tag string, value interface{}) (*gigo.Record, error) {
+	record := gigo.NewRecord(tag, nil)
+	switch fields := value.(type) {
+	case map[string]interface{}:
+		for key, v := range fields {
+			record.Set(key, v)
+		}
+	case map[interface{}]interface{}:
+		for key, v := range fields {
+			s, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("in_http: invalid key %v", key)
+			}
+			record.Set(s, v)
+		}
+	default:
+		return nil, errNotObject
+	}
+	return record, nil
+}
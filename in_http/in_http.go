@@ -0,0 +1,222 @@
+package in_http
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/najeira/gigo"
+)
+
+var (
+	_ gigo.Input = (*Reader)(nil)
+)
+
+const (
+	defaultMaxBodySize       = 8 * 1024 * 1024
+	defaultCloseTimeout      = time.Second * 5
+	defaultReadHeaderTimeout = time.Second * 10
+	defaultReadTimeout       = time.Second * 30
+	defaultIdleTimeout       = time.Second * 60
+)
+
+func init() {
+	gigo.RegisterInput("http", newInput)
+}
+
+type Config struct {
+	Addr   string
+	Logger gigo.Logger
+
+	// MaxBodySize is the max bytes of a body, after decompressed.
+	// Default is 8MB.
+	MaxBodySize int64
+
+	// CloseTimeout is the time for Stop to wait for the requests
+	// in progress. Default is 5s.
+	CloseTimeout time.Duration
+
+	// ReadHeaderTimeout and ReadTimeout are the time to read the header
+	// and the whole request. Default is 10s and 30s.
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+
+	// IdleTimeout closes a keep-alive connection waiting for the next
+	// request for the duration. Default is 60s.
+	IdleTimeout time.Duration
+}
+
+// Reader receives records by POST requests to "/<tag>".
+// The body is a JSON object, a JSON array of objects, NDJSON or msgpack
+// by Content-Type, and may be compressed by gzip. None of the records
+// are emitted if the body is invalid. The records are emitted in order
+// until an emit fails, so the ones before it are delivered even though
+// the request fails, and are emitted again if the client retries.
+type Reader struct {
+	address      string
+	logger       gigo.Logger
+	maxBodySize  int64
+	closeTimeout time.Duration
+
+	readHeaderTimeout time.Duration
+	readTimeout       time.Duration
+	idleTimeout       time.Duration
+
+	listener net.Listener
+	server   *http.Server
+	emitter  gigo.Emitter
+}
+
+func New(config Config) *Reader {
+	r := &Reader{
+		address:      config.Addr,
+		logger:       gigo.EnsureLogger(config.Logger),
+		maxBodySize:  config.MaxBodySize,
+		closeTimeout: config.CloseTimeout,
+
+		readHeaderTimeout: config.ReadHeaderTimeout,
+		readTimeout:       config.ReadTimeout,
+		idleTimeout:       config.IdleTimeout,
+	}
+	if r.maxBodySize <= 0 {
+		r.maxBodySize = defaultMaxBodySize
+	}
+	if r.closeTimeout <= 0 {
+		r.closeTimeout = defaultCloseTimeout
+	}
+	if r.readHeaderTimeout <= 0 {
+		r.readHeaderTimeout = defaultReadHeaderTimeout
+	}
+	if r.readTimeout <= 0 {
+		r.readTimeout = defaultReadTimeout
+	}
+	if r.idleTimeout <= 0 {
+		r.idleTimeout = defaultIdleTimeout
+	}
+	return r
+}
+
+func newInput(config gigo.PluginConfig) (gigo.Input, error) {
+	if err := config.Require("addr"); err != nil {
+		return nil, err
+	}
+	closeTimeout, err := config.Duration("close_timeout", defaultCloseTimeout)
+	if err != nil {
+		return nil, err
+	}
+	readHeaderTimeout, err := config.Duration("read_header_timeout", defaultReadHeaderTimeout)
+	if err != nil {
+		return nil, err
+	}
+	readTimeout, err := config.Duration("read_timeout", defaultReadTimeout)
+	if err != nil {
+		return nil, err
+	}
+	idleTimeout, err := config.Duration("idle_timeout", defaultIdleTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return New(Config{
+		Logger:       config.Logger(),
+		Addr:         config.String("addr", ""),
+		MaxBodySize:  config.Int("max_body_size", defaultMaxBodySize),
+		CloseTimeout: closeTimeout,
+
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		IdleTimeout:       idleTimeout,
+	}), nil
+}
+
+// Start listens and serves the requests until Stop.
+func (r *Reader) Start(e gigo.Emitter) error {
+	if r.listener != nil {
+		return gigo.ErrAlreadyStarted
+	}
+	ln, err := net.Listen("tcp", r.address)
+	if err != nil {
+		r.logger.Warnf("in_http: listen error %s", err)
+		return err
+	}
+	r.listener = ln
+	r.emitter = e
+	r.server = &http.Server{
+		Handler:           r,
+		ReadHeaderTimeout: r.readHeaderTimeout,
+		ReadTimeout:       r.readTimeout,
+		IdleTimeout:       r.idleTimeout,
+	}
+	r.logger.Infof("in_http: listen %s", r.address)
+
+	go r.serve(r.server, ln)
+	return nil
+}
+
+func (r *Reader) serve(server *http.Server, ln net.Listener) {
+	if err := server.Serve(ln); err != nil && err != http.ErrServerClosed {
+		r.logger.Warnf("in_http: serve error %s", err)
+	}
+}
+
+func (r *Reader) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		httpError(w, http.StatusMethodNotAllowed)
+		return
+	}
+	tag := strings.Trim(req.URL.Path, "/")
+	if tag == "" || strings.Contains(tag, "/") {
+		httpError(w, http.StatusNotFound)
+		return
+	}
+
+	records, status, err := r.readRecords(tag, req)
+	if err != nil {
+		r.logger.Infof("in_http: %s from %s", err, req.RemoteAddr)
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	for i, record := range records {
+		if err := r.emitter.Emit(record); err != nil {
+			r.logger.Warnf("in_http: emit error %s", err)
+			// tell the client the records already emitted
+			msg := fmt.Sprintf("%d of %d records emitted", i, len(records))
+			http.Error(w, msg, http.StatusServiceUnavailable)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func httpError(w http.ResponseWriter, status int) {
+	http.Error(w, http.StatusText(status), status)
+}
+
+func (r *Reader) Stop() error {
+	if r.listener == nil {
+		return gigo.ErrNotStarted
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), r.closeTimeout)
+	defer cancel()
+	err := r.server.Shutdown(ctx)
+	if err == context.DeadlineExceeded {
+		r.logger.Warnf("in_http: force close")
+		err = r.server.Close()
+	} else if err != nil {
+		r.logger.Warnf("in_http: close error %s", err)
+	}
+	r.listener = nil
+	r.server = nil
+	return err
+}
+
+func (r *Reader) Health() error {
+	if r.listener == nil {
+		return gigo.ErrNotStarted
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package in_http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vmihailenco/msgpack"
+
+	"github.com/najeira/gigo"
+	"github.com/najeira/gigo/testutil"
+)
+
+func TestReader(t *testing.T) {
+	l := testutil.Logger{}
+	var mu sync.Mutex
+	var rets []string
+	emitter := gigo.EmitterFunc(func(record *gigo.Record) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if record.GetString("msg") == "fail" {
+			return errors.New("test error")
+		}
+		rets = append(rets, record.Tag+":"+record.GetString("msg"))
+		return nil
+	})
+
+	r := New(Config{Logger: &l, Addr: "127.0.0.1:0", MaxBodySize: 64})
+	if err := r.Start(emitter); err != nil {
+		t.Fatal(err)
+	}
+	url := "http://" + r.listener.Addr().String()
+
+	packed, err := msgpack.Marshal(map[string]interface{}{"msg": "f"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte(`{"msg":"g"}`))
+	w.Close()
+	var bomb bytes.Buffer
+	w = gzip.NewWriter(&bomb)
+	w.Write([]byte(`{"msg":"` + strings.Repeat("x", 100) + `"}`))
+	w.Close()
+
+	tests := []struct {
+		method      string
+		path        string
+		contentType string
+		encoding    string
+		body        []byte
+		status      int
+		output      string
+	}{
+		{"POST", "/app.a", "", "", []byte(`{"msg":"a"}`), 204, "app.a:a"},
+		{"POST", "/app.b", "application/json; charset=utf-8", "",
+			[]byte(`[{"msg":"b"},{"msg":"c"}]`), 204, "app.b:b,app.b:c"},
+		{"POST", "/app.d", "application/x-ndjson", "",
+			[]byte("{\"msg\":\"d\"}\n\n{\"msg\":\"e\"}\n"), 204, "app.d:d,app.d:e"},
+		{"POST", "/app.f", "application/msgpack", "", packed, 204, "app.f:f"},
+		{"POST", "/app.g", "application/json", "gzip", gz.Bytes(), 204, "app.g:g"},
+		{"GET", "/app.h", "", "", nil, 405, ""},
+		{"POST", "/", "", "", []byte(`{"msg":"i"}`), 404, ""},
+		{"POST", "/app.j", "text/plain", "", []byte(`{"msg":"j"}`), 415, ""},
+		{"POST", "/app.k", "", "br", []byte(`{"msg":"k"}`), 415, ""},
+		{"POST", "/app.l", "", "", []byte(`[{"msg":"l"},"m"]`), 400, ""},
+		{"POST", "/app.n", "", "", []byte(strings.Repeat(" ", 65)), 413, ""},
+		{"POST", "/app.o", "", "gzip", bomb.Bytes(), 413, ""},
+		{"POST", "/app.p", "", "",
+			[]byte(`[{"msg":"p"},{"msg":"fail"},{"msg":"q"}]`), 503, "app.p:p"},
+	}
+
+	for _, test := range tests {
+		mu.Lock()
+		rets = nil
+		mu.Unlock()
+
+		req, err := http.NewRequest(test.method, url+test.path, bytes.NewReader(test.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.contentType != "" {
+			req.Header.Set("Content-Type", test.contentType)
+		}
+		if test.encoding != "" {
+			req.Header.Set("Content-Encoding", test.encoding)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		res.Body.Close()
+		if res.StatusCode != test.status {
+			t.Errorf("%s %s: invalid status %d", test.method, test.path, res.StatusCode)
+		}
+
+		mu.Lock()
+		sort.Strings(rets)
+		if ret := strings.Join(rets, ","); ret != test.output {
+			t.Errorf("%s %s: invalid emit %s", test.method, test.path, ret)
+		}
+		mu.Unlock()
+	}
+
+	// not to wait for the connections kept alive
+	http.DefaultClient.CloseIdleConnections()
+	if err := r.Stop(); err != nil {
+		t.Error(err)
+	}
+	if warns := l.Warn.String(); warns != "in_http: emit error test error\n" {
+		t.Error(warns)
+	}
+}
+
+func TestTimeouts(t *testing.T) {
+	r := New(Config{Addr: "127.0.0.1:0", ReadHeaderTimeout: time.Millisecond * 100})
+	if err := r.Start(gigo.EmitterFunc(func(record *gigo.Record) error {
+		return nil
+	})); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	conn, err := net.Dial("tcp", r.listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second * 2))
+
+	// a client not sending the header is closed
+	start := time.Now()
+	conn.Write([]byte("POST /app HTTP/1.1\r\n"))
+	ioutil.ReadAll(conn)
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("invalid timeout %s", d)
+	}
+}
@@ -0,0 +1,152 @@
+package in_net
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultCloseTimeout = time.Second * 5
+)
+
+// timeoutConn sets the read deadline before each Read, by the idle
+// timeout while waiting for a frame, or by the read timeout in a frame.
+// A Handler setting the read deadline, such as while reading a message,
+// is given it instead of the idle timeout until it sets the zero time.
+// The deadline is not later than the one set by closeBy.
+type timeoutConn struct {
+	net.Conn
+	idle time.Duration
+	read time.Duration
+
+	// frames is set by the frame handler to distinguish the read timeout.
+	frames  bool
+	inFrame bool
+
+	// deadline is set by the Handler.
+	deadline time.Time
+
+	// closeAt is the deadline in unix nanoseconds, or 0.
+	closeAt int64
+}
+
+func (c *timeoutConn) Read(b []byte) (int, error) {
+	timeout := c.idle
+	if c.inFrame {
+		timeout = c.read
+	}
+
+	deadline := c.deadline
+	if deadline.IsZero() && timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	if closeAt := atomic.LoadInt64(&c.closeAt); closeAt > 0 {
+		if t := time.Unix(0, closeAt); deadline.IsZero() || t.Before(deadline) {
+			deadline = t
+		}
+	}
+	c.Conn.SetReadDeadline(deadline)
+
+	n, err := c.Conn.Read(b)
+	if n > 0 && c.frames {
+		c.inFrame = true
+	}
+	return n, err
+}
+
+// SetReadDeadline sets the deadline of the next reads.
+// The zero time resets it to the idle timeout.
+func (c *timeoutConn) SetReadDeadline(t time.Time) error {
+	c.deadline = t
+	return nil
+}
+
+func (c *timeoutConn) SetDeadline(t time.Time) error {
+	c.deadline = t
+	return c.Conn.SetWriteDeadline(t)
+}
+
+// endFrame is called by the frame handler after each frame.
+func (c *timeoutConn) endFrame() {
+	c.inFrame = false
+}
+
+// closeBy makes the reads fail after the deadline.
+func (c *timeoutConn) closeBy(deadline time.Time) {
+	atomic.StoreInt64(&c.closeAt, deadline.UnixNano())
+	c.Conn.SetReadDeadline(deadline)
+}
+
+// connSet tracks the connections being handled.
+// The connections are refused after close until open.
+type connSet struct {
+	mu     sync.Mutex
+	conns  map[*timeoutConn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+func (s *connSet) open() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = false
+}
+
+// close refuses the new connections, so wait does not run
+// concurrently with add.
+func (s *connSet) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+}
+
+// add returns false if closed.
+func (s *connSet) add(c *timeoutConn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	if s.conns == nil {
+		s.conns = make(map[*timeoutConn]struct{})
+	}
+	s.conns[c] = struct{}{}
+	s.wg.Add(1)
+	return true
+}
+
+func (s *connSet) remove(c *timeoutConn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, c)
+	s.wg.Done()
+}
+
+func (s *connSet) each(fn func(c *timeoutConn)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		fn(c)
+	}
+}
+
+// wait waits for the connections to be removed until the timeout,
+// and returns false if timed out.
+func (s *connSet) wait(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
@@ -0,0 +1,200 @@
+package in_net
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/vmihailenco/msgpack"
+
+	"github.com/najeira/gigo"
+)
+
+const (
+	defaultMaxFrameSize = 1024 * 1024
+	maxOctetDigits      = 10
+)
+
+var (
+	errFrameTooLong = errors.New("in_net: frame too long")
+
+	cr = []byte{'\r'}
+)
+
+// Framing is the way to split the stream of a connection into records.
+type Framing int
+
+const (
+	// Newline splits the stream by "\n" or "\r\n".
+	Newline Framing = iota
+
+	// OctetCounted reads frames as "MSG-LEN SP MSG" of RFC 6587.
+	OctetCounted
+
+	// LengthPrefixed reads frames prefixed by a 4 bytes big endian length.
+	LengthPrefixed
+
+	// Msgpack reads a stream of msgpack maps as the fields of records.
+	Msgpack
+)
+
+// ParseFraming returns the framing named "newline", "octet_counted",
+// "length_prefixed" or "msgpack". Empty is Newline.
+func ParseFraming(name string) (Framing, error) {
+	switch strings.ToLower(name) {
+	case "", "newline":
+		return Newline, nil
+	case "octet_counted":
+		return OctetCounted, nil
+	case "length_prefixed":
+		return LengthPrefixed, nil
+	case "msgpack":
+		return Msgpack, nil
+	}
+	return Newline, fmt.Errorf("in_net: unknown framing %s", name)
+}
+
+// frameReader returns the next record of a connection, or io.EOF.
+// It returns errFrameTooLong for a frame skipped, and the next can be read.
+type frameReader func() (*gigo.Record, error)
+
+func (r *Reader) newFrameReader(rd io.Reader) frameReader {
+	br := bufio.NewReader(rd)
+	if r.framing == Msgpack {
+		dec := msgpack.NewDecoder(br)
+		return func() (*gigo.Record, error) {
+			var fields map[string]interface{}
+			if err := dec.Decode(&fields); err != nil {
+				return nil, err
+			}
+			record := gigo.NewRecord(r.tag, nil)
+			for key, value := range fields {
+				record.Set(key, value)
+			}
+			return record, nil
+		}
+	}
+
+	var read func(*bufio.Reader, int) ([]byte, error)
+	switch r.framing {
+	case OctetCounted:
+		read = readOctetCounted
+	case LengthPrefixed:
+		read = readLengthPrefixed
+	default:
+		read = readLine
+	}
+	return func() (*gigo.Record, error) {
+		data, err := read(br, r.maxFrameSize)
+		if err != nil {
+			return nil, err
+		}
+		if r.decoder != nil {
+			data = r.decoder.Decode(data)
+		}
+		return gigo.NewRecord(r.tag, data), nil
+	}
+}
+
+// readLine reads a line without the line end, skipping empty lines.
+func readLine(br *bufio.Reader, max int) ([]byte, error) {
+	var line []byte
+	for {
+		data, err := br.ReadSlice('\n')
+		line = append(line, data...)
+		if err == bufio.ErrBufferFull {
+			// "\r" at the end may be of the line end
+			if len(bytes.TrimSuffix(line, cr)) > max {
+				// discard the rest of the line
+				for err == bufio.ErrBufferFull {
+					_, err = br.ReadSlice('\n')
+				}
+				if err != nil {
+					return nil, err
+				}
+				return nil, errFrameTooLong
+			}
+			continue
+		} else if err != nil && (err != io.EOF || len(line) <= 0) {
+			return nil, err
+		}
+
+		line = trimLineEnd(line)
+		if len(line) > max {
+			return nil, errFrameTooLong
+		} else if len(line) > 0 {
+			return line, nil
+		} else if err == io.EOF {
+			return nil, err
+		}
+		line = nil
+	}
+}
+
+func trimLineEnd(line []byte) []byte {
+	if n := len(line); n > 0 && line[n-1] == '\n' {
+		line = line[:n-1]
+	}
+	if n := len(line); n > 0 && line[n-1] == '\r' {
+		line = line[:n-1]
+	}
+	return line
+}
+
+// readOctetCounted reads a frame of RFC 6587 octet counting.
+func readOctetCounted(br *bufio.Reader, max int) ([]byte, error) {
+	var size int
+	for i := 0; ; i++ {
+		c, err := br.ReadByte()
+		if err != nil {
+			if err == io.EOF && i > 0 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		if c == ' ' && i > 0 {
+			break
+		} else if c < '0' || c > '9' || i >= maxOctetDigits || (i == 0 && c == '0') {
+			return nil, fmt.Errorf("in_net: invalid octet count %q", c)
+		}
+		size = size*10 + int(c-'0')
+	}
+	return readFrame(br, int64(size), max)
+}
+
+// readLengthPrefixed reads a frame prefixed by a 4 bytes big endian length.
+func readLengthPrefixed(br *bufio.Reader, max int) ([]byte, error) {
+	var prefix [4]byte
+	if _, err := io.ReadFull(br, prefix[:]); err != nil {
+		return nil, err
+	}
+	return readFrame(br, int64(binary.BigEndian.Uint32(prefix[:])), max)
+}
+
+// readFrame reads size bytes, or skips them if larger than max.
+func readFrame(br *bufio.Reader, size int64, max int) ([]byte, error) {
+	if size > int64(max) {
+		if _, err := io.CopyN(ioutil.Discard, br, size); err != nil {
+			return nil, noEOF(err)
+		}
+		return nil, errFrameTooLong
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(br, data); err != nil {
+		return nil, noEOF(err)
+	}
+	return data, nil
+}
+
+// noEOF returns io.ErrUnexpectedEOF for io.EOF in the middle of a frame.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -1,49 +1,292 @@
 package in_net
 
 import (
+	"crypto/tls"
+	"errors"
 	"io"
 	"net"
+	"time"
 
 	"github.com/najeira/gigo"
+	"github.com/najeira/gigo/charset"
 )
 
 var (
-	_ io.Closer = (*Reader)(nil)
+	_ io.Closer     = (*Reader)(nil)
+	_ gigo.Input    = (*Reader)(nil)
+	_ gigo.Reloader = (*Reader)(nil)
+
+	errNoHandler = errors.New("in_net: no packet handler")
+)
+
+const (
+	defaultTag    = "in_net"
+	remoteAddrKey = "remote_addr"
 )
 
+func init() {
+	gigo.RegisterInput("net", newInput)
+}
+
 type Handler func(net.Conn)
 
 type Config struct {
+	// Net is a stream network such as "tcp" and "unix",
+	// or a datagram network such as "udp" and "unixgram".
 	Net     string
 	Addr    string
+	Tag     string
 	Handler Handler
 	Logger  gigo.Logger
+
+	// PacketHandler handles each datagram for a datagram network.
+	// Each datagram is a record if nil.
+	PacketHandler PacketHandler
+
+	// ReadBufferSize is the size of the socket receive buffer
+	// for a datagram network. The system default is used if 0.
+	ReadBufferSize int
+
+	// Framing splits the stream into records if no Handler.
+	// Default is Newline.
+	Framing Framing
+
+	// MaxFrameSize is the max bytes of a frame or a datagram.
+	// Longer frames are skipped, except for Msgpack streams.
+	// Default is 1MB.
+	MaxFrameSize int
+
+	// Decoder converts the frames to UTF-8 if not nil.
+	// It is not used with Handler and Msgpack.
+	Decoder *charset.Decoder
+
+	// TLS serves TLS for a stream network if not nil.
+	TLS *TLSConfig
+
+	// MaxConnections limits the connections handled at the same time.
+	// The connections over the limit wait to be accepted. No limit if 0.
+	MaxConnections int
+
+	// IdleTimeout closes a connection receiving no frame for the duration.
+	// It is the timeout of each read for Handler, unless the Handler sets
+	// the read deadline of the connection. No timeout if 0.
+	IdleTimeout time.Duration
+
+	// ReadTimeout closes a connection stalled in the middle of a frame
+	// for the duration. No timeout if 0.
+	ReadTimeout time.Duration
+
+	// CloseTimeout is the time for Close to wait for the connections
+	// to end before closing them. Default is 5s.
+	CloseTimeout time.Duration
 }
 
 type Reader struct {
+	network  string
+	address  string
+	tag      string
 	listener net.Listener
 	handler  Handler
 	logger   gigo.Logger
+	decoder  *charset.Decoder
+
+	framing      Framing
+	maxFrameSize int
+
+	packetConn     net.PacketConn
+	packetHandler  PacketHandler
+	readBufferSize int
+
+	// onConn and onPacket are the handlers of the current Start or Open.
+	onConn   Handler
+	onPacket PacketHandler
+
+	tlsConfig *TLSConfig
+	tls       *tlsServer
+
+	maxConns     int
+	idleTimeout  time.Duration
+	readTimeout  time.Duration
+	closeTimeout time.Duration
+	sem          chan struct{}
+	conns        connSet
+	closing      chan struct{}
+	acceptDone   chan struct{}
+	packetDone   chan struct{}
 }
 
-func Open(config Config) (*Reader, error) {
+func New(config Config) *Reader {
 	r := &Reader{
+		network: config.Net,
+		address: config.Addr,
+		tag:     config.Tag,
 		handler: config.Handler,
 		logger:  gigo.EnsureLogger(config.Logger),
+		decoder: config.Decoder,
+
+		framing:      config.Framing,
+		maxFrameSize: config.MaxFrameSize,
+
+		packetHandler:  config.PacketHandler,
+		readBufferSize: config.ReadBufferSize,
+
+		tlsConfig: config.TLS,
+
+		maxConns:     config.MaxConnections,
+		idleTimeout:  config.IdleTimeout,
+		readTimeout:  config.ReadTimeout,
+		closeTimeout: config.CloseTimeout,
+	}
+	if r.tag == "" {
+		r.tag = defaultTag
+	}
+	if r.closeTimeout <= 0 {
+		r.closeTimeout = defaultCloseTimeout
+	}
+	if r.maxFrameSize <= 0 {
+		r.maxFrameSize = defaultMaxFrameSize
 	}
-	if err := r.open(config.Net, config.Addr); err != nil {
+	return r
+}
+
+func newInput(config gigo.PluginConfig) (gigo.Input, error) {
+	if err := config.Require("addr"); err != nil {
+		return nil, err
+	}
+	framing, err := ParseFraming(config.String("framing", ""))
+	if err != nil {
+		return nil, err
+	}
+	decoder, err := charset.New(config)
+	if err != nil {
+		return nil, err
+	}
+	idleTimeout, err := config.Duration("idle_timeout", 0)
+	if err != nil {
+		return nil, err
+	}
+	readTimeout, err := config.Duration("read_timeout", 0)
+	if err != nil {
+		return nil, err
+	}
+	closeTimeout, err := config.Duration("close_timeout", defaultCloseTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return New(Config{
+		Logger:       config.Logger(),
+		Net:          config.String("net", "tcp"),
+		Addr:         config.String("addr", ""),
+		Tag:          config.String("tag", ""),
+		Framing:      framing,
+		MaxFrameSize: int(config.Int("max_frame_size", defaultMaxFrameSize)),
+		Decoder:      decoder,
+
+		ReadBufferSize: int(config.Int("read_buffer_size", 0)),
+
+		TLS: TLSConfigFrom(config),
+
+		MaxConnections: int(config.Int("max_connections", 0)),
+		IdleTimeout:    idleTimeout,
+		ReadTimeout:    readTimeout,
+		CloseTimeout:   closeTimeout,
+	}), nil
+}
+
+func Open(config Config) (*Reader, error) {
+	r := New(config)
+	r.onConn = r.handler
+	r.onPacket = r.packetHandler
+	if err := r.open(r.network, r.address); err != nil {
 		return nil, err
 	}
 	return r, nil
 }
 
+// Start listens and handles connections or datagrams until Stop.
+// If no handler is configured, each frame or datagram received is
+// emitted to e as a record with the remote address.
+func (r *Reader) Start(e gigo.Emitter) error {
+	if r.listener != nil || r.packetConn != nil {
+		return gigo.ErrAlreadyStarted
+	}
+	r.onConn = r.handler
+	if r.onConn == nil {
+		r.onConn = r.frameHandler(e)
+	}
+	r.onPacket = r.packetHandler
+	if r.onPacket == nil {
+		r.onPacket = r.datagramHandler(e)
+	}
+	return r.open(r.network, r.address)
+}
+
+func (r *Reader) frameHandler(e gigo.Emitter) Handler {
+	return func(conn net.Conn) {
+		remoteAddr := conn.RemoteAddr().String()
+		tc, _ := conn.(*timeoutConn)
+		if tc != nil {
+			tc.frames = true
+		}
+		read := r.newFrameReader(conn)
+		for {
+			record, err := read()
+			if tc != nil {
+				tc.endFrame()
+			}
+			if err == errFrameTooLong {
+				r.logger.Warnf("in_net: %s from %s", err, remoteAddr)
+				continue
+			} else if err == io.EOF {
+				return
+			} else if isTimeout(err) {
+				r.logger.Infof("in_net: timeout %s", remoteAddr)
+				return
+			} else if err != nil {
+				r.logger.Infof("in_net: read error %s", err)
+				return
+			}
+			record.Set(remoteAddrKey, remoteAddr)
+			if err := e.Emit(record); err != nil {
+				r.logger.Warnf("in_net: emit error %s", err)
+			}
+		}
+	}
+}
+
 func (r *Reader) open(network, address string) error {
+	r.closing = make(chan struct{})
+	r.conns.open()
+	if r.maxConns > 0 {
+		r.sem = make(chan struct{}, r.maxConns)
+	}
+
+	if isPacket(network) {
+		if r.tlsConfig != nil {
+			return errTLSOverDatagrams
+		}
+		return r.listenPacket(network, address)
+	}
+
+	if r.tlsConfig != nil {
+		s, err := newTLSServer(*r.tlsConfig)
+		if err != nil {
+			r.logger.Warnf("in_net: TLS error %s", err)
+			return err
+		}
+		r.tls = s
+	}
+
 	ln, err := net.Listen(network, address)
 	if err != nil {
 		r.logger.Warnf("in_net: listen error %s", err)
 		return err
 	}
+	if r.tls != nil {
+		ln = tls.NewListener(ln, r.tls.tlsConfig())
+	}
 	r.listener = ln
+	r.acceptDone = make(chan struct{})
 	r.logger.Infof("in_net: listen %s %s", network, address)
 
 	go r.accept(ln)
@@ -52,32 +295,138 @@ func (r *Reader) open(network, address string) error {
 }
 
 func (r *Reader) accept(ln net.Listener) {
+	defer close(r.acceptDone)
+
 	for {
+		if r.sem != nil {
+			select {
+			case r.sem <- struct{}{}:
+			case <-r.closing:
+				return
+			}
+		}
+
 		conn, err := ln.Accept()
 		if err != nil {
-			r.logger.Warnf("in_net: accept error %s", err)
+			r.release()
+			select {
+			case <-r.closing:
+				r.logger.Debugf("in_net: accept end")
+			default:
+				r.logger.Warnf("in_net: accept error %s", err)
+			}
 			return
 		}
 		r.logger.Debugf("in_net: accept %s->%s",
 			conn.LocalAddr().String(), conn.RemoteAddr().String())
-		go r.handleConn(conn)
+
+		tc := &timeoutConn{Conn: conn, idle: r.idleTimeout, read: r.readTimeout}
+		if !r.conns.add(tc) {
+			// accepted while closing
+			conn.Close()
+			r.release()
+			return
+		}
+		go r.handleConn(tc)
+	}
+}
+
+func (r *Reader) release() {
+	if r.sem != nil {
+		<-r.sem
 	}
-	panic("unreachable")
 }
 
-func (r *Reader) handleConn(conn net.Conn) {
-	defer conn.Close()
-	if r.handler != nil {
-		r.handler(conn)
+func (r *Reader) handleConn(conn *timeoutConn) {
+	defer func() {
+		conn.Close()
+		r.release()
+		r.conns.remove(conn)
+	}()
+	if r.onConn != nil {
+		r.onConn(conn)
 	}
 }
 
+func isTimeout(err error) bool {
+	ne, ok := err.(net.Error)
+	return ok && ne.Timeout()
+}
+
+func (r *Reader) Stop() error {
+	return r.Close()
+}
+
+// Reload reloads the TLS certificates for the new connections.
+func (r *Reader) Reload() error {
+	if r.tls == nil {
+		return nil
+	}
+	if err := r.tls.load(); err != nil {
+		r.logger.Warnf("in_net: TLS reload error %s", err)
+		return err
+	}
+	r.logger.Infof("in_net: TLS reloaded")
+	return nil
+}
+
+// Addr returns the address listened, or nil if not started.
+func (r *Reader) Addr() net.Addr {
+	if r.packetConn != nil {
+		return r.packetConn.LocalAddr()
+	} else if r.listener != nil {
+		return r.listener.Addr()
+	}
+	return nil
+}
+
+func (r *Reader) Health() error {
+	if r.listener == nil && r.packetConn == nil {
+		return gigo.ErrNotStarted
+	}
+	return nil
+}
+
+// Close stops accepting and waits for the connections to end
+// until CloseTimeout, then closes the rest.
+// It returns gigo.ErrNotStarted if not started or already closed.
 func (r *Reader) Close() error {
+	if r.listener == nil && r.packetConn == nil {
+		return gigo.ErrNotStarted
+	}
+	close(r.closing)
+
+	if r.packetConn != nil {
+		err := r.packetConn.Close()
+		if err != nil {
+			r.logger.Warnf("in_net: packet conn close error %s", err)
+		} else {
+			r.logger.Debugf("in_net: packet conn close")
+		}
+		<-r.packetDone
+		r.packetConn = nil
+		return err
+	}
+
 	err := r.listener.Close()
+	r.listener = nil
 	if err != nil {
 		r.logger.Warnf("in_net: listener close error %s", err)
 	} else {
 		r.logger.Debugf("in_net: listener close")
 	}
+	<-r.acceptDone
+
+	r.conns.close()
+	deadline := time.Now().Add(r.closeTimeout)
+	r.conns.each(func(c *timeoutConn) {
+		c.closeBy(deadline)
+	})
+	if !r.conns.wait(r.closeTimeout) {
+		r.conns.each(func(c *timeoutConn) {
+			r.logger.Warnf("in_net: force close %s", c.RemoteAddr())
+			c.Close()
+		})
+	}
 	return err
 }
@@ -1,13 +1,29 @@
 package in_net
 
 import (
+	"bufio"
 	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"io"
 	"io/ioutil"
+	"math/big"
 	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/vmihailenco/msgpack"
+
+	"github.com/najeira/gigo"
 	"github.com/najeira/gigo/testutil"
 )
 
@@ -16,10 +32,15 @@ func TestReader(t *testing.T) {
 
 	l := testutil.Logger{}
 
+	var mu sync.Mutex
 	var buf bytes.Buffer
 	handler := func(conn net.Conn) {
+		data := make([]byte, 64)
 		for {
-			_, err := io.Copy(&buf, conn)
+			n, err := conn.Read(data)
+			mu.Lock()
+			buf.Write(data[:n])
+			mu.Unlock()
 			if err != nil {
 				return
 			}
@@ -59,6 +80,7 @@ func TestReader(t *testing.T) {
 	if err := conn.Close(); err != nil {
 		t.Error(err)
 	}
+	time.Sleep(time.Millisecond * 10)
 
 	conn, err = net.Dial("tcp", addr)
 	if err != nil {
@@ -76,7 +98,9 @@ func TestReader(t *testing.T) {
 
 	time.Sleep(time.Millisecond * 10)
 
+	mu.Lock()
 	ret, err := ioutil.ReadAll(&buf)
+	mu.Unlock()
 	rets := string(ret)
 	if err != nil {
 		t.Error(err)
@@ -103,3 +127,494 @@ func checkNoWarnNoError(t *testing.T, l testutil.Logger) {
 		t.Error(errs)
 	}
 }
+
+func TestFraming(t *testing.T) {
+	tests := []struct {
+		framing Framing
+		input   []byte
+		output  string
+	}{
+		{Newline, []byte("this\r\nis\n\ntest"), "this,is,test"},
+		{Newline, []byte("short\n" + strings.Repeat("x", 20) + "\nend\n"), "short,end"},
+		{Newline, []byte("0123456789\n0123456789a\n0123456789\r\n0123456789a\r\nend"), "0123456789,0123456789,end"},
+		{OctetCounted, []byte("4 this2 is4 test"), "this,is,test"},
+		{OctetCounted, []byte("20 " + strings.Repeat("x", 20) + "3 end"), "end"},
+		{LengthPrefixed, []byte("\x00\x00\x00\x04this\x00\x00\x00\x02is"), "this,is"},
+	}
+
+	for _, test := range tests {
+		l := testutil.Logger{}
+		var mu sync.Mutex
+		var rets []string
+		emitter := gigo.EmitterFunc(func(record *gigo.Record) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if record.GetString("remote_addr") == "" {
+				t.Error("no remote address")
+			}
+			rets = append(rets, string(record.Raw))
+			return nil
+		})
+
+		p := New(Config{
+			Logger:       &l,
+			Net:          "tcp",
+			Addr:         "127.0.0.1:0",
+			Framing:      test.framing,
+			MaxFrameSize: 10,
+		})
+		if err := p.Start(emitter); err != nil {
+			t.Fatal(err)
+		}
+
+		conn, err := net.Dial("tcp", p.listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.Write(test.input); err != nil {
+			t.Error(err)
+		}
+		conn.Close()
+		time.Sleep(time.Millisecond * 10)
+
+		if err := p.Stop(); err != nil {
+			t.Error(err)
+		}
+
+		mu.Lock()
+		if ret := strings.Join(rets, ","); ret != test.output {
+			t.Errorf("%d: invalid emit %s, expected %s", test.framing, ret, test.output)
+		}
+		mu.Unlock()
+	}
+}
+
+func TestReadLine(t *testing.T) {
+	// lines over the buffer of 16 bytes
+	x20 := strings.Repeat("x", 20)
+	input := x20 + "\n" + x20 + "y\n" + x20 + "\r\n" + x20 + "y\r\n" + "end"
+	br := bufio.NewReaderSize(strings.NewReader(input), 16)
+
+	var rets []string
+	for {
+		line, err := readLine(br, 20)
+		if err == io.EOF {
+			break
+		} else if err == errFrameTooLong {
+			rets = append(rets, "too long")
+		} else if err != nil {
+			t.Fatal(err)
+		} else {
+			rets = append(rets, string(line))
+		}
+	}
+	expected := strings.Join([]string{x20, "too long", x20, "too long", "end"}, ",")
+	if ret := strings.Join(rets, ","); ret != expected {
+		t.Errorf("invalid lines %s", ret)
+	}
+}
+
+func TestRestart(t *testing.T) {
+	p := New(Config{Net: "tcp", Addr: "127.0.0.1:0"})
+	if err := p.Close(); err != gigo.ErrNotStarted {
+		t.Errorf("invalid close before start %v", err)
+	}
+
+	for _, name := range []string{"first", "second"} {
+		var mu sync.Mutex
+		var rets []string
+		emitter := gigo.EmitterFunc(func(record *gigo.Record) error {
+			mu.Lock()
+			defer mu.Unlock()
+			rets = append(rets, string(record.Raw))
+			return nil
+		})
+		if err := p.Start(emitter); err != nil {
+			t.Fatal(err)
+		}
+		conn, err := net.Dial("tcp", p.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Write([]byte(name + "\n"))
+		conn.Close()
+		time.Sleep(time.Millisecond * 10)
+		if err := p.Stop(); err != nil {
+			t.Error(err)
+		}
+
+		// emitted to the emitter of the current Start
+		mu.Lock()
+		if ret := strings.Join(rets, ","); ret != name {
+			t.Errorf("invalid emit %s, expected %s", ret, name)
+		}
+		mu.Unlock()
+	}
+
+	if err := p.Close(); err != gigo.ErrNotStarted {
+		t.Errorf("invalid close after stop %v", err)
+	}
+}
+
+func TestFramingMsgpack(t *testing.T) {
+	var mu sync.Mutex
+	var rets []*gigo.Record
+	emitter := gigo.EmitterFunc(func(record *gigo.Record) error {
+		mu.Lock()
+		defer mu.Unlock()
+		rets = append(rets, record)
+		return nil
+	})
+
+	p := New(Config{Net: "tcp", Addr: "127.0.0.1:0", Framing: Msgpack})
+	if err := p.Start(emitter); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := net.Dial("tcp", p.listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := msgpack.NewEncoder(conn)
+	enc.Encode(map[string]interface{}{"message": "a"})
+	enc.Encode(map[string]interface{}{"message": "b", "level": 1})
+	conn.Close()
+	time.Sleep(time.Millisecond * 10)
+
+	if err := p.Stop(); err != nil {
+		t.Error(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(rets) != 2 {
+		t.Fatalf("invalid records %d", len(rets))
+	}
+	if m := rets[1].GetString("message"); m != "b" {
+		t.Errorf("invalid message %s", m)
+	}
+	if rets[1].GetString("remote_addr") == "" {
+		t.Error("no remote address")
+	}
+}
+
+func TestPacket(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		network string
+		addr    string
+	}{
+		{"udp", "127.0.0.1:0"},
+		{"unixgram", filepath.Join(dir, "in_net.sock")},
+	}
+
+	for _, test := range tests {
+		l := testutil.Logger{}
+		var mu sync.Mutex
+		var rets []string
+		emitter := gigo.EmitterFunc(func(record *gigo.Record) error {
+			mu.Lock()
+			defer mu.Unlock()
+			rets = append(rets, string(record.Raw))
+			return nil
+		})
+
+		p := New(Config{
+			Logger:         &l,
+			Net:            test.network,
+			Addr:           test.addr,
+			ReadBufferSize: 64 * 1024,
+			MaxFrameSize:   10,
+		})
+		if err := p.Start(emitter); err != nil {
+			t.Fatal(err)
+		}
+
+		var conn net.Conn
+		if test.network == "udp" {
+			conn, err = net.Dial("udp", p.packetConn.LocalAddr().String())
+		} else {
+			laddr := &net.UnixAddr{Name: test.addr + ".client", Net: "unixgram"}
+			raddr := &net.UnixAddr{Name: test.addr, Net: "unixgram"}
+			conn, err = net.DialUnix("unixgram", laddr, raddr)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, data := range []string{"this\n", "is", strings.Repeat("x", 20), "test"} {
+			if _, err := conn.Write([]byte(data)); err != nil {
+				t.Error(err)
+			}
+		}
+		conn.Close()
+		time.Sleep(time.Millisecond * 10)
+
+		if err := p.Stop(); err != nil {
+			t.Error(err)
+		}
+
+		mu.Lock()
+		if ret := strings.Join(rets, ","); ret != "this,is,test" {
+			t.Errorf("%s: invalid emit %s", test.network, ret)
+		}
+		mu.Unlock()
+	}
+}
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCert(t *testing.T, cn string, serial int64, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+func (c *testCert) write(t *testing.T, certFile, keyFile string) {
+	if err := ioutil.WriteFile(certFile, c.pem, 0600); err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	if err := ioutil.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (c *testCert) tlsCert() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.cert.Raw}, PrivateKey: c.key}
+}
+
+func TestTLS(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCert(t, "ca", 1, nil)
+	caFile := filepath.Join(dir, "ca.pem")
+	if err := ioutil.WriteFile(caFile, ca.pem, 0600); err != nil {
+		t.Fatal(err)
+	}
+	certFile := filepath.Join(dir, "server.pem")
+	keyFile := filepath.Join(dir, "server.key")
+	newTestCert(t, "server", 2, ca).write(t, certFile, keyFile)
+
+	var mu sync.Mutex
+	var rets []string
+	emitter := gigo.EmitterFunc(func(record *gigo.Record) error {
+		mu.Lock()
+		defer mu.Unlock()
+		rets = append(rets, string(record.Raw))
+		return nil
+	})
+
+	p := New(Config{
+		Net:  "tcp",
+		Addr: "127.0.0.1:0",
+		TLS: &TLSConfig{
+			CertFile:     certFile,
+			KeyFile:      keyFile,
+			ClientCAFile: caFile,
+			ClientCNs:    []string{"allowed"},
+		},
+	})
+	if err := p.Start(emitter); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	send := func(client *testCert, data string) *x509.Certificate {
+		config := &tls.Config{RootCAs: roots, ServerName: "127.0.0.1"}
+		if client != nil {
+			config.Certificates = []tls.Certificate{client.tlsCert()}
+		}
+		conn, err := tls.Dial("tcp", p.listener.Addr().String(), config)
+		if err != nil {
+			return nil
+		}
+		defer conn.Close()
+		conn.Write([]byte(data))
+		// wait for the server to verify the client
+		conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+		conn.Read(make([]byte, 1))
+		return conn.ConnectionState().PeerCertificates[0]
+	}
+
+	send(newTestCert(t, "allowed", 3, ca), "allowed\n")
+	send(newTestCert(t, "denied", 4, ca), "denied\n")
+	send(nil, "anonymous\n")
+
+	// reload a new server certificate
+	newTestCert(t, "server", 5, ca).write(t, certFile, keyFile)
+	if err := p.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if cert := send(newTestCert(t, "allowed", 6, ca), "reloaded\n"); cert == nil {
+		t.Error("no server certificate")
+	} else if serial := cert.SerialNumber.Int64(); serial != 5 {
+		t.Errorf("invalid server certificate %d", serial)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if ret := strings.Join(rets, ","); ret != "allowed,reloaded" {
+		t.Errorf("invalid emit %s", ret)
+	}
+
+	if _, err := newTLSServer(TLSConfig{CertFile: certFile, KeyFile: keyFile, MinVersion: "2.0"}); err == nil {
+		t.Error("no error for invalid version")
+	}
+	if _, err := newTLSServer(TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCNs: []string{"allowed"}}); err != errNoClientCA {
+		t.Errorf("invalid error for client CNs without CA: %v", err)
+	}
+}
+
+func TestConnections(t *testing.T) {
+	l := testutil.Logger{}
+	var mu sync.Mutex
+	var rets []string
+	emitter := gigo.EmitterFunc(func(record *gigo.Record) error {
+		mu.Lock()
+		defer mu.Unlock()
+		rets = append(rets, string(record.Raw))
+		return nil
+	})
+	emitted := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return strings.Join(rets, ",")
+	}
+
+	p := New(Config{
+		Logger:         &l,
+		Net:            "tcp",
+		Addr:           "127.0.0.1:0",
+		MaxConnections: 1,
+		IdleTimeout:    time.Millisecond * 300,
+		ReadTimeout:    time.Millisecond * 100,
+		CloseTimeout:   time.Millisecond * 100,
+	})
+	if err := p.Start(emitter); err != nil {
+		t.Fatal(err)
+	}
+	addr := p.listener.Addr().String()
+
+	dial := func(data string) net.Conn {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.Write([]byte(data)); err != nil {
+			t.Error(err)
+		}
+		return conn
+	}
+
+	// the second connection waits for the first one
+	c1 := dial("first\n")
+	c2 := dial("second\n")
+	time.Sleep(time.Millisecond * 50)
+	if ret := emitted(); ret != "first" {
+		t.Errorf("invalid emit %s", ret)
+	}
+	c1.Close()
+	time.Sleep(time.Millisecond * 50)
+	if ret := emitted(); ret != "first,second" {
+		t.Errorf("invalid emit %s", ret)
+	}
+
+	// idle timeout
+	start := time.Now()
+	if _, err := ioutil.ReadAll(c2); err != nil {
+		t.Error(err)
+	}
+	if d := time.Since(start); d < time.Millisecond*200 || d > time.Second {
+		t.Errorf("invalid idle timeout %s", d)
+	}
+	c2.Close()
+
+	// read timeout in a frame
+	c3 := dial("partial")
+	start = time.Now()
+	if _, err := ioutil.ReadAll(c3); err != nil {
+		t.Error(err)
+	}
+	if d := time.Since(start); d > time.Millisecond*250 {
+		t.Errorf("invalid read timeout %s", d)
+	}
+	c3.Close()
+
+	// Close waits for the connection until CloseTimeout
+	c4 := dial("last\n")
+	defer c4.Close()
+	time.Sleep(time.Millisecond * 50)
+	start = time.Now()
+	if err := p.Stop(); err != nil {
+		t.Error(err)
+	}
+	if d := time.Since(start); d > time.Millisecond*500 {
+		t.Errorf("invalid close %s", d)
+	}
+	if ret := emitted(); ret != "first,second,last" {
+		t.Errorf("invalid emit %s", ret)
+	}
+	if errs := l.Error.String(); errs != "" {
+		t.Error(errs)
+	}
+
+	// no connection is added while waiting on close
+	var s connSet
+	s.close()
+	if s.add(&timeoutConn{}) {
+		t.Error("added after close")
+	}
+	if !s.wait(time.Millisecond) {
+		t.Error("wait timed out")
+	}
+}
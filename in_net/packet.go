@@ -0,0 +1,118 @@
+package in_net
+
+import (
+	"net"
+
+	"github.com/vmihailenco/msgpack"
+
+	"github.com/najeira/gigo"
+)
+
+// PacketHandler handles a datagram received from addr.
+// The data is reused after the handler returns.
+type PacketHandler func(data []byte, addr net.Addr)
+
+// isPacket reports whether the network is datagram-oriented.
+func isPacket(network string) bool {
+	switch network {
+	case "udp", "udp4", "udp6", "unixgram":
+		return true
+	}
+	return false
+}
+
+func (r *Reader) datagramHandler(e gigo.Emitter) PacketHandler {
+	return func(data []byte, addr net.Addr) {
+		record, err := r.packetRecord(data)
+		if err != nil {
+			r.logger.Infof("in_net: decode error %s", err)
+			return
+		} else if record == nil {
+			return
+		}
+		if addr != nil {
+			record.Set(remoteAddrKey, addr.String())
+		}
+		if err := e.Emit(record); err != nil {
+			r.logger.Warnf("in_net: emit error %s", err)
+		}
+	}
+}
+
+// packetRecord returns a record of a datagram, or nil if empty.
+func (r *Reader) packetRecord(data []byte) (*gigo.Record, error) {
+	if r.framing == Msgpack {
+		var fields map[string]interface{}
+		if err := msgpack.Unmarshal(data, &fields); err != nil {
+			return nil, err
+		}
+		record := gigo.NewRecord(r.tag, nil)
+		for key, value := range fields {
+			record.Set(key, value)
+		}
+		return record, nil
+	}
+
+	data = trimLineEnd(data)
+	if len(data) <= 0 {
+		return nil, nil
+	}
+	if r.decoder != nil {
+		data = r.decoder.Decode(data)
+	} else {
+		data = append([]byte(nil), data...)
+	}
+	return gigo.NewRecord(r.tag, data), nil
+}
+
+func (r *Reader) listenPacket(network, address string) error {
+	if r.onPacket == nil {
+		return errNoHandler
+	}
+
+	pc, err := net.ListenPacket(network, address)
+	if err != nil {
+		r.logger.Warnf("in_net: listen error %s", err)
+		return err
+	}
+	if r.readBufferSize > 0 {
+		if c, ok := pc.(interface {
+			SetReadBuffer(int) error
+		}); ok {
+			if err := c.SetReadBuffer(r.readBufferSize); err != nil {
+				r.logger.Warnf("in_net: set read buffer error %s", err)
+			}
+		}
+	}
+	r.packetConn = pc
+	r.packetDone = make(chan struct{})
+	r.logger.Infof("in_net: listen %s %s", network, address)
+
+	go r.readPackets(pc)
+
+	return nil
+}
+
+func (r *Reader) readPackets(pc net.PacketConn) {
+	defer close(r.packetDone)
+
+	// one more byte to detect the datagrams too large
+	buf := make([]byte, r.maxFrameSize+1)
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			select {
+			case <-r.closing:
+				r.logger.Debugf("in_net: read end")
+			default:
+				r.logger.Infof("in_net: read error %s", err)
+			}
+			return
+		}
+		if n > r.maxFrameSize {
+			r.logger.Warnf("in_net: %s from %s", errFrameTooLong, addr)
+			continue
+		}
+		r.onPacket(buf[:n], addr)
+	}
+}
@@ -0,0 +1,150 @@
+package in_net
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"github.com/najeira/gigo"
+)
+
+var (
+	errNoClientCert     = errors.New("in_net: no client certificate")
+	errNoClientCA       = errors.New("in_net: client CNs require a client CA file")
+	errTLSOverDatagrams = errors.New("in_net: TLS over datagrams is not supported")
+
+	tlsVersions = map[string]uint16{
+		"1.0": tls.VersionTLS10,
+		"1.1": tls.VersionTLS11,
+		"1.2": tls.VersionTLS12,
+		"1.3": tls.VersionTLS13,
+	}
+)
+
+type TLSConfig struct {
+	CertFile string
+	KeyFile  string
+
+	// MinVersion is "1.0", "1.1", "1.2" or "1.3". Default is "1.2".
+	MinVersion string
+
+	// ClientCAFile enables mutual TLS. The clients must present
+	// a certificate signed by the CAs in the PEM file.
+	ClientCAFile string
+
+	// ClientCNs allows only the client certificates whose common name
+	// is in the list if not empty. It requires ClientCAFile.
+	ClientCNs []string
+}
+
+// TLSConfigFrom returns the TLS settings of "tls_cert_file",
+// "tls_key_file", "tls_min_version", "tls_client_ca_file" and
+// "tls_client_cns". It returns nil if "tls_cert_file" is not set.
+func TLSConfigFrom(config gigo.PluginConfig) *TLSConfig {
+	if config.String("tls_cert_file", "") == "" {
+		return nil
+	}
+	return &TLSConfig{
+		CertFile:     config.String("tls_cert_file", ""),
+		KeyFile:      config.String("tls_key_file", ""),
+		MinVersion:   config.String("tls_min_version", ""),
+		ClientCAFile: config.String("tls_client_ca_file", ""),
+		ClientCNs:    config.Strings("tls_client_cns"),
+	}
+}
+
+// tlsServer holds the certificates loaded, which are replaced by load
+// without restarting the listener.
+type tlsServer struct {
+	config     TLSConfig
+	minVersion uint16
+	clientCNs  map[string]bool
+
+	mu      sync.RWMutex
+	current *tls.Config
+}
+
+func newTLSServer(config TLSConfig) (*tlsServer, error) {
+	s := &tlsServer{config: config, minVersion: tls.VersionTLS12}
+	if config.MinVersion != "" {
+		v, ok := tlsVersions[config.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("in_net: unknown TLS version %s", config.MinVersion)
+		}
+		s.minVersion = v
+	}
+	if len(config.ClientCNs) > 0 {
+		if config.ClientCAFile == "" {
+			// the client certificates are not requested without the CAs
+			return nil, errNoClientCA
+		}
+		s.clientCNs = make(map[string]bool, len(config.ClientCNs))
+		for _, cn := range config.ClientCNs {
+			s.clientCNs[cn] = true
+		}
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load reads the certificate and the client CAs.
+// The connections accepted after load use them.
+func (s *tlsServer) load() error {
+	cert, err := tls.LoadX509KeyPair(s.config.CertFile, s.config.KeyFile)
+	if err != nil {
+		return err
+	}
+	c := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   s.minVersion,
+	}
+
+	if s.config.ClientCAFile != "" {
+		pem, err := ioutil.ReadFile(s.config.ClientCAFile)
+		if err != nil {
+			return err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("in_net: no certificate in %s", s.config.ClientCAFile)
+		}
+		c.ClientCAs = pool
+		c.ClientAuth = tls.RequireAndVerifyClientCert
+		if s.clientCNs != nil {
+			c.VerifyPeerCertificate = s.verifyClientCN
+		}
+	}
+
+	s.mu.Lock()
+	s.current = c
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *tlsServer) verifyClientCN(rawCerts [][]byte, chains [][]*x509.Certificate) error {
+	if len(chains) <= 0 || len(chains[0]) <= 0 {
+		return errNoClientCert
+	}
+	cn := chains[0][0].Subject.CommonName
+	if !s.clientCNs[cn] {
+		return fmt.Errorf("in_net: client %s is not allowed", cn)
+	}
+	return nil
+}
+
+// tlsConfig returns a config for a listener using the current certificates.
+func (s *tlsServer) tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: s.minVersion,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			s.mu.RLock()
+			defer s.mu.RUnlock()
+			return s.current, nil
+		},
+	}
+}
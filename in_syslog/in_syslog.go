@@ -0,0 +1,155 @@
+package in_syslog
+
+import (
+	"time"
+
+	"github.com/najeira/gigo"
+	"github.com/najeira/gigo/in_net"
+)
+
+var (
+	_ gigo.Input    = (*Reader)(nil)
+	_ gigo.Reloader = (*Reader)(nil)
+)
+
+const (
+	defaultTag = "syslog"
+)
+
+func init() {
+	gigo.RegisterInput("syslog", newInput)
+}
+
+type Config struct {
+	// Net is "udp" or "tcp" and so on. Default is "udp".
+	Net    string
+	Addr   string
+	Tag    string
+	Logger gigo.Logger
+
+	// Framing splits TCP streams. Senders use Newline or OctetCounted.
+	Framing in_net.Framing
+
+	// Location is the zone of RFC 3164 timestamps. Default is time.Local.
+	Location *time.Location
+
+	// TLS serves syslog over TLS (RFC 5425) for "tcp" if not nil.
+	TLS *in_net.TLSConfig
+}
+
+// Reader receives syslog messages by in_net and emits them as records
+// tagged "Tag.facility.severity" with the fields of the message.
+// The messages failed to parse are emitted with Tag and "message".
+type Reader struct {
+	net      *in_net.Reader
+	tag      string
+	logger   gigo.Logger
+	location *time.Location
+}
+
+func New(config Config) *Reader {
+	r := &Reader{
+		tag:      config.Tag,
+		logger:   gigo.EnsureLogger(config.Logger),
+		location: config.Location,
+	}
+	if r.tag == "" {
+		r.tag = defaultTag
+	}
+	if r.location == nil {
+		r.location = time.Local
+	}
+	network := config.Net
+	if network == "" {
+		network = "udp"
+	}
+	r.net = in_net.New(in_net.Config{
+		Net:     network,
+		Addr:    config.Addr,
+		Tag:     r.tag,
+		Logger:  r.logger,
+		Framing: config.Framing,
+		TLS:     config.TLS,
+	})
+	return r
+}
+
+func newInput(config gigo.PluginConfig) (gigo.Input, error) {
+	if err := config.Require("addr"); err != nil {
+		return nil, err
+	}
+	framing, err := in_net.ParseFraming(config.String("framing", ""))
+	if err != nil {
+		return nil, err
+	}
+	location := time.Local
+	if name := config.String("timezone", ""); name != "" {
+		if location, err = time.LoadLocation(name); err != nil {
+			return nil, err
+		}
+	}
+	return New(Config{
+		Logger:   config.Logger(),
+		Net:      config.String("net", "udp"),
+		Addr:     config.String("addr", ""),
+		Tag:      config.String("tag", ""),
+		Framing:  framing,
+		Location: location,
+		TLS:      in_net.TLSConfigFrom(config),
+	}), nil
+}
+
+// Start listens and emits the messages received to e until Stop.
+func (r *Reader) Start(e gigo.Emitter) error {
+	return r.net.Start(gigo.EmitterFunc(func(record *gigo.Record) error {
+		r.parse(record)
+		return e.Emit(record)
+	}))
+}
+
+// parse sets the fields of the message to the record.
+func (r *Reader) parse(record *gigo.Record) {
+	m, err := Parse(record.Raw, time.Now(), r.location)
+	if err != nil {
+		r.logger.Infof("in_syslog: %s: %q", err, record.Raw)
+		record.Set("message", string(record.Raw))
+		return
+	}
+
+	record.Tag = r.tag + "." + m.FacilityName() + "." + m.SeverityName()
+	if !m.Timestamp.IsZero() {
+		record.Time = m.Timestamp
+	}
+	record.Set("facility", m.FacilityName())
+	record.Set("severity", m.SeverityName())
+	setString(record, "hostname", m.Hostname)
+	setString(record, "app_name", m.AppName)
+	setString(record, "proc_id", m.ProcID)
+	setString(record, "msg_id", m.MsgID)
+	if m.StructuredData != nil {
+		sd := make(map[string]interface{}, len(m.StructuredData))
+		for id, params := range m.StructuredData {
+			sd[id] = params
+		}
+		record.Set("structured_data", sd)
+	}
+	record.Set("message", m.Message)
+}
+
+func setString(record *gigo.Record, key, value string) {
+	if value != "" {
+		record.Set(key, value)
+	}
+}
+
+func (r *Reader) Stop() error {
+	return r.net.Stop()
+}
+
+func (r *Reader) Reload() error {
+	return r.net.Reload()
+}
+
+func (r *Reader) Health() error {
+	return r.net.Health()
+}
@@ -0,0 +1,146 @@
+package in_syslog
+
+import (
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/najeira/gigo"
+)
+
+func TestParse(t *testing.T) {
+	now := time.Date(2017, 1, 10, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		input  string
+		output Message
+	}{
+		{
+			"<34>Oct 11 22:14:15 mymachine su: 'su root' failed",
+			Message{
+				Facility:  4,
+				Severity:  2,
+				Timestamp: time.Date(2016, 10, 11, 22, 14, 15, 0, time.UTC),
+				Hostname:  "mymachine",
+				AppName:   "su",
+				Message:   "'su root' failed",
+			},
+		},
+		{
+			"<13>Jan  9 01:02:03 host sshd[123]: Accepted",
+			Message{
+				Facility:  1,
+				Severity:  5,
+				Timestamp: time.Date(2017, 1, 9, 1, 2, 3, 0, time.UTC),
+				Hostname:  "host",
+				AppName:   "sshd",
+				ProcID:    "123",
+				Message:   "Accepted",
+			},
+		},
+		{
+			"<14>just a message",
+			Message{Facility: 1, Severity: 6, Message: "just a message"},
+		},
+		{
+			"<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 " +
+				`[exampleSDID@32473 iut="3" eventSource="Appli\"cation"][origin ip="192.0.2.1"] ` +
+				"\xef\xbb\xbfAn application event",
+			Message{
+				Facility:  20,
+				Severity:  5,
+				Version:   1,
+				Timestamp: time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC),
+				Hostname:  "mymachine.example.com",
+				AppName:   "evntslog",
+				MsgID:     "ID47",
+				StructuredData: map[string]map[string]string{
+					"exampleSDID@32473": {"iut": "3", "eventSource": `Appli"cation`},
+					"origin":            {"ip": "192.0.2.1"},
+				},
+				Message: "An application event",
+			},
+		},
+		{
+			"<34>1 - - - - - -",
+			Message{Facility: 4, Severity: 2, Version: 1},
+		},
+	}
+
+	for _, test := range tests {
+		m, err := Parse([]byte(test.input), now, time.UTC)
+		if err != nil {
+			t.Errorf("%q: %s", test.input, err)
+			continue
+		}
+		if !m.Timestamp.Equal(test.output.Timestamp) {
+			t.Errorf("%q: invalid timestamp %s", test.input, m.Timestamp)
+		}
+		m.Timestamp = test.output.Timestamp
+		if !reflect.DeepEqual(*m, test.output) {
+			t.Errorf("%q: invalid message %+v", test.input, *m)
+		}
+	}
+
+	for _, input := range []string{"no pri", "<192>x", "<1>1 - - - - - [a"} {
+		if _, err := Parse([]byte(input), now, time.UTC); err == nil {
+			t.Errorf("%q: no error", input)
+		}
+	}
+}
+
+func TestReader(t *testing.T) {
+	var mu sync.Mutex
+	var records []*gigo.Record
+	emitter := gigo.EmitterFunc(func(record *gigo.Record) error {
+		mu.Lock()
+		defer mu.Unlock()
+		records = append(records, record)
+		return nil
+	})
+
+	addr := "127.0.0.1:9754"
+	r := New(Config{Addr: addr, Location: time.UTC})
+	if err := r.Start(emitter); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Write([]byte("<134>1 2017-01-01T00:00:00Z web nginx 10 - - GET /"))
+	conn.Write([]byte("broken"))
+	conn.Close()
+	time.Sleep(10 * time.Millisecond)
+
+	if err := r.Stop(); err != nil {
+		t.Error(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(records) != 2 {
+		t.Fatalf("invalid records %d", len(records))
+	}
+	if tag := records[0].Tag; tag != "syslog.local0.info" {
+		t.Errorf("invalid tag %s", tag)
+	}
+	for key, value := range map[string]string{
+		"facility": "local0",
+		"severity": "info",
+		"hostname": "web",
+		"app_name": "nginx",
+		"proc_id":  "10",
+		"message":  "GET /",
+	} {
+		if v := records[0].GetString(key); v != value {
+			t.Errorf("invalid %s: %s", key, v)
+		}
+	}
+	if records[1].Tag != "syslog" || records[1].GetString("message") != "broken" {
+		t.Errorf("invalid record %v", records[1])
+	}
+}
@@ -0,0 +1,269 @@
+package in_syslog
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"time"
+)
+
+const (
+	nilValue = "-"
+	rfc3164  = "Jan _2 15:04:05"
+)
+
+var (
+	errNoPri       = errors.New("in_syslog: no PRI")
+	errInvalidPri  = errors.New("in_syslog: invalid PRI")
+	errInvalidSD   = errors.New("in_syslog: invalid structured data")
+	errInvalidTime = errors.New("in_syslog: invalid timestamp")
+
+	facilities = []string{
+		"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+		"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+		"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+	}
+
+	severities = []string{
+		"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug",
+	}
+
+	bom = []byte{0xef, 0xbb, 0xbf}
+)
+
+// Message is a syslog message of RFC 3164 or RFC 5424.
+// The fields not in the message are empty.
+type Message struct {
+	Facility  int
+	Severity  int
+	Version   int // 0 for RFC 3164
+	Timestamp time.Time
+	Hostname  string
+	AppName   string
+	ProcID    string
+	MsgID     string
+
+	// StructuredData maps SD-IDs to their parameters.
+	StructuredData map[string]map[string]string
+
+	Message string
+}
+
+// FacilityName returns the keyword of the facility such as "local0".
+func (m *Message) FacilityName() string {
+	if m.Facility < 0 || m.Facility >= len(facilities) {
+		return strconv.Itoa(m.Facility)
+	}
+	return facilities[m.Facility]
+}
+
+// SeverityName returns the keyword of the severity such as "err".
+func (m *Message) SeverityName() string {
+	if m.Severity < 0 || m.Severity >= len(severities) {
+		return strconv.Itoa(m.Severity)
+	}
+	return severities[m.Severity]
+}
+
+// Parse parses a syslog message of RFC 5424, or RFC 3164 otherwise.
+// The time of RFC 3164 is taken in loc for the year of now.
+func Parse(data []byte, now time.Time, loc *time.Location) (*Message, error) {
+	pri, rest, err := parsePri(data)
+	if err != nil {
+		return nil, err
+	}
+	m := &Message{Facility: pri / 8, Severity: pri % 8}
+	if len(rest) >= 2 && rest[0] >= '1' && rest[0] <= '9' && rest[1] == ' ' {
+		err = m.parse5424(rest)
+	} else {
+		m.parse3164(rest, now, loc)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func parsePri(data []byte) (int, []byte, error) {
+	if len(data) <= 0 || data[0] != '<' {
+		return 0, nil, errNoPri
+	}
+	end := bytes.IndexByte(data, '>')
+	if end < 2 || end > 4 {
+		return 0, nil, errInvalidPri
+	}
+	pri, err := strconv.Atoi(string(data[1:end]))
+	if err != nil || pri < 0 || pri > 191 {
+		return 0, nil, errInvalidPri
+	}
+	return pri, data[end+1:], nil
+}
+
+// nextField returns the field before a space and the rest.
+func nextField(data []byte) (string, []byte) {
+	i := bytes.IndexByte(data, ' ')
+	if i < 0 {
+		return string(data), nil
+	}
+	return string(data[:i]), data[i+1:]
+}
+
+func nilable(s string) string {
+	if s == nilValue {
+		return ""
+	}
+	return s
+}
+
+func (m *Message) parse5424(data []byte) error {
+	var field string
+	field, data = nextField(data)
+	version, err := strconv.Atoi(field)
+	if err != nil {
+		return err
+	}
+	m.Version = version
+
+	field, data = nextField(data)
+	if field != nilValue {
+		t, err := time.Parse(time.RFC3339Nano, field)
+		if err != nil {
+			return errInvalidTime
+		}
+		m.Timestamp = t
+	}
+
+	field, data = nextField(data)
+	m.Hostname = nilable(field)
+	field, data = nextField(data)
+	m.AppName = nilable(field)
+	field, data = nextField(data)
+	m.ProcID = nilable(field)
+	field, data = nextField(data)
+	m.MsgID = nilable(field)
+
+	data, err = m.parseSD(data)
+	if err != nil {
+		return err
+	}
+	if len(data) > 0 && data[0] == ' ' {
+		data = data[1:]
+	}
+	m.Message = string(bytes.TrimPrefix(data, bom))
+	return nil
+}
+
+// parseSD parses STRUCTURED-DATA and returns the rest.
+func (m *Message) parseSD(data []byte) ([]byte, error) {
+	if len(data) > 0 && data[0] == '-' {
+		return data[1:], nil
+	}
+	for len(data) > 0 && data[0] == '[' {
+		end := bytes.IndexAny(data, " ]")
+		if end < 0 {
+			return nil, errInvalidSD
+		}
+		params := make(map[string]string)
+		if m.StructuredData == nil {
+			m.StructuredData = make(map[string]map[string]string)
+		}
+		m.StructuredData[string(data[1:end])] = params
+		data = data[end:]
+
+		for len(data) > 0 && data[0] == ' ' {
+			data = data[1:]
+			eq := bytes.IndexByte(data, '=')
+			if eq < 0 || eq+1 >= len(data) || data[eq+1] != '"' {
+				return nil, errInvalidSD
+			}
+			name := string(data[:eq])
+			value, rest, err := parseParamValue(data[eq+2:])
+			if err != nil {
+				return nil, err
+			}
+			params[name] = value
+			data = rest
+		}
+		if len(data) <= 0 || data[0] != ']' {
+			return nil, errInvalidSD
+		}
+		data = data[1:]
+	}
+	return data, nil
+}
+
+// parseParamValue parses PARAM-VALUE after the opening quote,
+// unescaping '"', '\' and ']'.
+func parseParamValue(data []byte) (string, []byte, error) {
+	var value []byte
+	for i := 0; i < len(data); i++ {
+		switch c := data[i]; c {
+		case '"':
+			return string(value), data[i+1:], nil
+		case '\\':
+			if i+1 < len(data) {
+				switch next := data[i+1]; next {
+				case '"', '\\', ']':
+					value = append(value, next)
+					i++
+					continue
+				}
+			}
+			value = append(value, c)
+		default:
+			value = append(value, c)
+		}
+	}
+	return "", nil, errInvalidSD
+}
+
+// parse3164 parses "Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG" leniently.
+// The parts not recognized are left in the message.
+func (m *Message) parse3164(data []byte, now time.Time, loc *time.Location) {
+	if len(data) >= len(rfc3164) {
+		if t, err := time.ParseInLocation(rfc3164, string(data[:len(rfc3164)]), loc); err == nil {
+			t = t.AddDate(now.In(loc).Year(), 0, 0)
+			if t.After(now.AddDate(0, 0, 1)) {
+				// December messages received in January
+				t = t.AddDate(-1, 0, 0)
+			}
+			m.Timestamp = t
+			data = bytes.TrimPrefix(data[len(rfc3164):], []byte{' '})
+
+			var host string
+			if host, data = nextField(data); data == nil {
+				// no hostname but a message
+				data = []byte(host)
+			} else {
+				m.Hostname = host
+			}
+		}
+	}
+
+	// TAG is up to 32 alphanumeric characters
+	for i := 0; i < len(data) && i <= 32; i++ {
+		c := data[i]
+		if c == ':' || c == '[' {
+			if i <= 0 {
+				break
+			}
+			m.AppName = string(data[:i])
+			rest := data[i:]
+			if c == '[' {
+				end := bytes.IndexByte(rest, ']')
+				if end < 0 {
+					m.AppName = ""
+					break
+				}
+				m.ProcID = string(rest[1:end])
+				rest = rest[end+1:]
+			}
+			rest = bytes.TrimPrefix(rest, []byte{':'})
+			data = bytes.TrimPrefix(rest, []byte{' '})
+			break
+		} else if c == ' ' {
+			break
+		}
+	}
+	m.Message = string(data)
+}
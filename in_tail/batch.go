@@ -0,0 +1,202 @@
+package in_tail
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/najeira/gigo"
+	"github.com/najeira/gigo/charset"
+)
+
+const (
+	batchName = "in_tail_batch"
+)
+
+var (
+	_ gigo.Input = (*Batch)(nil)
+)
+
+func init() {
+	gigo.RegisterInput("tail_batch", newBatchInput)
+}
+
+type BatchConfig struct {
+	// Path is a directory or a glob pattern of the files to read.
+	// The files ending with .gz or .zst are decompressed.
+	Path string
+	Tag  string
+
+	// Multiline joins the lines of an event into a record.
+	Multiline *Multiline
+
+	// Decoder converts the lines to UTF-8 if not nil.
+	Decoder *charset.Decoder
+
+	// MaxLineSize, Oversize, TruncateMarker and ErrorTag
+	// are the same as Config.
+	MaxLineSize    int
+	Oversize       Oversize
+	TruncateMarker string
+	ErrorTag       string
+}
+
+// Batch reads the files once, such as archived logs, and emits
+// each line as a record with the path.
+type Batch struct {
+	gigo.Mixin
+	lineOptions
+
+	path string
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewBatch(config BatchConfig) *Batch {
+	b := &Batch{}
+	b.Name = batchName
+	b.path = config.Path
+	b.tag = config.Tag
+	if b.tag == "" {
+		b.tag = batchName
+	}
+	b.multiline = config.Multiline
+	b.decoder = config.Decoder
+	b.maxSize = config.MaxLineSize
+	b.oversize = config.Oversize
+	b.marker = []byte(config.TruncateMarker)
+	if config.TruncateMarker == "" {
+		b.marker = []byte(defaultTruncateMarker)
+	}
+	b.errorTag = config.ErrorTag
+	if b.errorTag == "" {
+		b.errorTag = b.tag + "." + oversizeKey
+	}
+	return b
+}
+
+func newBatchInput(config gigo.PluginConfig) (gigo.Input, error) {
+	if err := config.Require("path"); err != nil {
+		return nil, err
+	}
+	multiline, err := newMultiline(config)
+	if err != nil {
+		return nil, err
+	}
+	decoder, err := charset.New(config)
+	if err != nil {
+		return nil, err
+	}
+	oversize, err := ParseOversize(config.String("oversize", ""))
+	if err != nil {
+		return nil, err
+	}
+	return NewBatch(BatchConfig{
+		Path:           config.String("path", ""),
+		Tag:            config.String("tag", ""),
+		Multiline:      multiline,
+		Decoder:        decoder,
+		MaxLineSize:    int(config.Int("max_line_size", 0)),
+		Oversize:       oversize,
+		TruncateMarker: config.String("truncate_marker", ""),
+		ErrorTag:       config.String("error_tag", ""),
+	}), nil
+}
+
+// files returns the sorted paths of the files to read.
+func (b *Batch) files() ([]string, error) {
+	info, err := os.Stat(b.path)
+	if err == nil && info.IsDir() {
+		infos, err := ioutil.ReadDir(b.path)
+		if err != nil {
+			return nil, err
+		}
+		paths := make([]string, 0, len(infos))
+		for _, info := range infos {
+			if info.Mode().IsRegular() {
+				paths = append(paths, filepath.Join(b.path, info.Name()))
+			}
+		}
+		return paths, nil
+	}
+
+	paths, err := filepath.Glob(b.path)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Start reads the files in the background. Done is closed
+// after all the lines are emitted.
+func (b *Batch) Start(e gigo.Emitter) error {
+	if b.done != nil {
+		return gigo.ErrAlreadyStarted
+	}
+	paths, err := b.files()
+	if err != nil {
+		b.Errorf("files error %s", err)
+		return err
+	}
+
+	b.stop = make(chan struct{})
+	b.done = make(chan struct{})
+	chunks := make(chan chunk, 16)
+	go b.readFiles(paths, chunks)
+	go func(done chan struct{}) {
+		defer close(done)
+		b.emitChunks(chunks, e, b.Infof)
+		b.Infof("read %d files", len(paths))
+	}(b.done)
+	return nil
+}
+
+func (b *Batch) readFiles(paths []string, out chan<- chunk) {
+	defer close(out)
+	for _, path := range paths {
+		select {
+		case <-b.stop:
+			return
+		default:
+		}
+		if err := b.readFile(path, out); err != nil {
+			b.Errorf("read %s error %s", path, err)
+		}
+	}
+}
+
+func (b *Batch) readFile(path string, out chan<- chunk) error {
+	rc, err := openDecompress(path)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	b.Debugf("read %s", path)
+	return readLines(path, rc, b.newLineBuffer(), out, b.stop)
+}
+
+// Done returns a channel closed after all the files are read.
+func (b *Batch) Done() <-chan struct{} {
+	return b.done
+}
+
+// Stop stops reading the files and waits for the lines read to be emitted.
+func (b *Batch) Stop() error {
+	if b.done == nil {
+		return gigo.ErrNotStarted
+	}
+	close(b.stop)
+	<-b.done
+	b.done = nil
+	return nil
+}
+
+func (b *Batch) Health() error {
+	if b.done == nil {
+		return gigo.ErrNotStarted
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package in_tail
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// isCompressed reports whether the path is a gzip or zstd file.
+func isCompressed(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gz", ".zst":
+		return true
+	}
+	return false
+}
+
+type decompressReader struct {
+	io.Reader
+	closers []func() error
+}
+
+func (d *decompressReader) Close() error {
+	var lastErr error
+	for _, c := range d.closers {
+		if err := c(); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// openDecompress opens the path decompressing by the extension.
+func openDecompress(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gz":
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return &decompressReader{zr, []func() error{zr.Close, file.Close}}, nil
+	case ".zst":
+		zr, err := zstd.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		closeZstd := func() error {
+			zr.Close()
+			return nil
+		}
+		return &decompressReader{zr, []func() error{closeZstd, file.Close}}, nil
+	}
+	return file, nil
+}
+
+// chunkSender returns a function sending a copy of the data to out.
+func chunkSender(path string, out chan<- chunk) func([]byte, bool) {
+	return func(data []byte, oversize bool) {
+		c := chunk{path: path, data: make([]byte, len(data)), oversize: oversize}
+		copy(c.data, data)
+		out <- c
+	}
+}
+
+// readLines sends the lines of r to out until EOF or stop is closed.
+func readLines(path string, r io.Reader, lines *lineBuffer, out chan<- chunk, stop <-chan struct{}) error {
+	send := chunkSender(path, out)
+	buf := make([]byte, readBufferSize)
+	for {
+		select {
+		case <-stop:
+			return nil
+		default:
+		}
+
+		n, err := r.Read(buf)
+		if n > 0 {
+			lines.write(buf[:n], send)
+		}
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+	lines.flush(send)
+	return nil
+}
@@ -0,0 +1,376 @@
+package in_tail
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	readBufferSize = 32 * 1024
+)
+
+// chunk is complete lines read from a file,
+// or the head of an oversize line.
+type chunk struct {
+	path     string
+	data     []byte
+	oversize bool
+}
+
+// follower reads a file as it grows, like `tail -F`.
+// When the path is renamed and created again, the rest of the old file
+// is read before switching to the new one. When the file is truncated,
+// it is read from the head again.
+type follower struct {
+	r *Reader
+
+	path      string
+	interval  time.Duration
+	positions *positionFile
+
+	file    *os.File
+	info    os.FileInfo
+	offset  int64
+	buf     []byte
+	lines   *lineBuffer
+	rotated *position
+	head    uint64 // hash of the head of the file up to headLen
+	headLen int64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newFollower(r *Reader, path string, interval time.Duration, positions *positionFile) *follower {
+	return &follower{
+		r:         r,
+		path:      path,
+		interval:  interval,
+		positions: positions,
+		buf:       make([]byte, readBufferSize),
+		lines:     r.newLineBuffer(),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// open opens the path and seeks to the saved position if any,
+// otherwise to the end if fromEnd.
+// It is not an error that the path does not exist yet.
+func (f *follower) open(fromEnd bool) error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			f.r.Infof("%s not found, waiting", f.path)
+			return nil
+		}
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	var offset int64
+	if saved, ok := f.savedOffset(info); ok {
+		offset = saved
+	} else if fromEnd {
+		offset = info.Size()
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return err
+	}
+
+	f.file = file
+	f.info = info
+	f.offset = offset
+	f.head, f.headLen = 0, 0
+	f.lines.reset()
+	f.savePosition()
+	f.r.Debugf("open %s at %d", f.path, offset)
+	return nil
+}
+
+// savedOffset returns the offset to resume from the position file.
+// If the file was rotated or truncated since saved, it returns 0.
+func (f *follower) savedOffset(info os.FileInfo) (int64, bool) {
+	if f.positions == nil {
+		return 0, false
+	}
+	pos, ok := f.positions.get(f.path)
+	if !ok {
+		return 0, false
+	}
+	if pos.Inode != inode(info) {
+		f.r.Infof("%s rotated since the last position", f.path)
+		if f.r.readRotated {
+			f.rotated = &pos
+		}
+		return 0, true
+	} else if pos.Offset > info.Size() {
+		f.r.Infof("%s truncated since the last position", f.path)
+		return 0, true
+	}
+	return pos.Offset, true
+}
+
+// savePosition saves the offset of the lines sent.
+func (f *follower) savePosition() {
+	if f.positions == nil || f.info == nil {
+		return
+	}
+	offset := f.offset - int64(f.lines.pending())
+	f.positions.set(f.path, position{Inode: inode(f.info), Offset: offset, Head: f.hashHead(offset)})
+}
+
+// hashHead returns the hash of the head of the file up to offset,
+// hashing again only while the head is shorter than headSize.
+func (f *follower) hashHead(offset int64) uint64 {
+	n := headLength(offset)
+	if n == 0 {
+		return 0
+	} else if n == f.headLen {
+		return f.head
+	}
+	head, ok := hashHead(io.NewSectionReader(f.file, 0, n), n)
+	if !ok {
+		return 0
+	}
+	f.head, f.headLen = head, n
+	return head
+}
+
+func (f *follower) close() {
+	if f.file == nil {
+		return
+	}
+	if err := f.file.Close(); err != nil {
+		f.r.Infof("close error %s", err)
+	}
+	f.file = nil
+	f.info = nil
+	f.offset = 0
+	f.head, f.headLen = 0, 0
+	f.lines.reset()
+}
+
+// run sends the lines to out until stop is closed,
+// then sends the remaining data.
+func (f *follower) run(out chan<- chunk) {
+	defer close(f.done)
+	defer f.close()
+
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	for {
+		f.readRotated(out)
+		f.readToEnd(out)
+		select {
+		case <-f.stop:
+			f.readToEnd(out)
+			f.flush(out)
+			return
+		case <-ticker.C:
+			f.check(out)
+		}
+	}
+}
+
+// readToEnd sends the complete lines until the end of the file.
+// An incomplete last line is kept until the rest is written.
+func (f *follower) readToEnd(out chan<- chunk) {
+	if f.file == nil {
+		return
+	}
+	for {
+		n, err := f.file.Read(f.buf)
+		if n > 0 {
+			f.offset += int64(n)
+			f.lines.write(f.buf[:n], f.sender(out))
+			f.savePosition()
+		}
+		if err == io.EOF {
+			return
+		} else if err != nil {
+			f.r.Infof("read error %s", err)
+			return
+		}
+	}
+}
+
+// flush sends the incomplete last line.
+func (f *follower) flush(out chan<- chunk) {
+	f.lines.flush(f.sender(out))
+	f.savePosition()
+}
+
+func (f *follower) sender(out chan<- chunk) func([]byte, bool) {
+	return chunkSender(f.path, out)
+}
+
+// check detects rotation and truncation of the file.
+func (f *follower) check(out chan<- chunk) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			f.r.Infof("stat error %s", err)
+		}
+		// the file is moved, keep reading the current file
+		return
+	}
+
+	if f.file == nil {
+		// the file is created
+		if err := f.open(false); err != nil {
+			f.r.Infof("open error %s", err)
+		}
+		return
+	}
+
+	if !os.SameFile(info, f.info) {
+		// the file is rotated
+		f.readToEnd(out)
+		f.flush(out)
+		f.r.Infof("%s rotated", f.path)
+		f.close()
+		if err := f.open(false); err != nil {
+			f.r.Infof("open error %s", err)
+		}
+		// the old file is read to the end already
+		f.rotated = nil
+	} else if info.Size() < f.offset {
+		f.r.Infof("%s truncated", f.path)
+		if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+			f.r.Infof("seek error %s", err)
+			return
+		}
+		f.offset = 0
+		f.head, f.headLen = 0, 0
+		f.lines.reset()
+		f.savePosition()
+	}
+}
+
+// readRotated reads the rest of the file rotated since the last position.
+// The file may be compressed by logrotate.
+func (f *follower) readRotated(out chan<- chunk) {
+	if f.rotated == nil {
+		return
+	}
+	pos := *f.rotated
+	f.rotated = nil
+
+	path, err := f.findRotated(pos)
+	if err != nil {
+		f.r.Infof("glob error %s", err)
+		return
+	} else if path == "" {
+		f.r.Infof("rotated file of %s not found", f.path)
+		return
+	}
+
+	rc, err := openDecompress(path)
+	if err != nil {
+		f.r.Infof("open error %s", err)
+		return
+	}
+	defer rc.Close()
+
+	if _, err := io.CopyN(ioutil.Discard, rc, pos.Offset); err != nil {
+		f.r.Infof("skip error %s", err)
+		return
+	}
+	if err := readLines(path, rc, f.r.newLineBuffer(), out, f.stop); err != nil {
+		f.r.Infof("read error %s", err)
+		return
+	}
+	f.r.Infof("read rotated %s from %d", path, pos.Offset)
+}
+
+// findRotated returns the uncompressed sibling having the inode, or the
+// compressed sibling as long as the offset and having the same head in the
+// rotation order from the newest, such as "app.log.1.gz" before
+// "app.log.2.gz". The modified times are not used, as compressing may
+// change them.
+func (f *follower) findRotated(pos position) (string, error) {
+	var paths []string
+	for _, pattern := range []string{f.path + ".*", f.path + "-*"} {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return "", err
+		}
+		paths = append(paths, matches...)
+	}
+
+	var compressed []string
+	for _, path := range paths {
+		if isCompressed(path) {
+			compressed = append(compressed, path)
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if inode(info) == pos.Inode {
+			return path, nil
+		}
+	}
+
+	sortRotated(f.path, compressed)
+	for _, path := range compressed {
+		if hasPosition(path, pos) {
+			return path, nil
+		}
+	}
+	return "", nil
+}
+
+// sortRotated sorts the rotated paths from the newest, "path.N" by N
+// and then the others such as "path-20170102" by the name descending.
+func sortRotated(base string, paths []string) {
+	index := func(path string) (int, bool) {
+		s := strings.TrimSuffix(path, filepath.Ext(path))
+		if !strings.HasPrefix(s, base+".") {
+			return 0, false
+		}
+		n, err := strconv.Atoi(s[len(base)+1:])
+		return n, err == nil
+	}
+	sort.SliceStable(paths, func(i, j int) bool {
+		ni, oki := index(paths[i])
+		nj, okj := index(paths[j])
+		if oki && okj {
+			return ni < nj
+		} else if oki != okj {
+			return oki
+		}
+		return paths[i] > paths[j]
+	})
+}
+
+// hasPosition reports whether the decompressed file is as long as the
+// offset and has the head of the position, if known.
+func hasPosition(path string, pos position) bool {
+	rc, err := openDecompress(path)
+	if err != nil {
+		return false
+	}
+	defer rc.Close()
+	n := headLength(pos.Offset)
+	if head, ok := hashHead(rc, n); !ok || (pos.Head != 0 && head != pos.Head) {
+		return false
+	}
+	c, _ := io.CopyN(ioutil.Discard, rc, pos.Offset-n)
+	return c == pos.Offset-n
+}
@@ -0,0 +1,13 @@
+//go:build windows
+// +build windows
+
+package in_tail
+
+import (
+	"os"
+)
+
+// inode is not available on Windows. Positions are resumed by the offset only.
+func inode(info os.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,245 @@
+package in_tail
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/najeira/gigo"
+	"github.com/najeira/gigo/charset"
+)
+
+const (
+	defaultTruncateMarker = "...(truncated)"
+	oversizeKey           = "oversize"
+)
+
+// Oversize is the handling of the lines longer than the max line size.
+// Reading goes on after an oversize line in any case.
+type Oversize int
+
+const (
+	// Truncate emits the head of the line with a marker,
+	// and discards the rest.
+	Truncate Oversize = iota
+
+	// Split emits the line in pieces of the max line size.
+	Split
+
+	// Divert emits the head of the line with the error tag,
+	// and discards the rest.
+	Divert
+)
+
+// ParseOversize returns the policy named "truncate", "split" or "divert".
+// Empty is Truncate.
+func ParseOversize(name string) (Oversize, error) {
+	switch strings.ToLower(name) {
+	case "", "truncate":
+		return Truncate, nil
+	case "split":
+		return Split, nil
+	case "divert":
+		return Divert, nil
+	}
+	return Truncate, fmt.Errorf("in_tail: unknown oversize %s", name)
+}
+
+// lineBuffer cuts the data read into lines not longer than max.
+type lineBuffer struct {
+	max   int
+	split bool
+
+	partial []byte
+	lines   []byte
+
+	// skip is true while discarding the rest of an oversize line.
+	skip bool
+}
+
+func newLineBuffer(max int, oversize Oversize) *lineBuffer {
+	return &lineBuffer{max: max, split: oversize == Split}
+}
+
+// write calls send with the complete lines, and with the head
+// of each oversize line unless split. An incomplete last line
+// is kept until the rest is written.
+func (b *lineBuffer) write(data []byte, send func(data []byte, oversize bool)) {
+	b.lines = b.lines[:0]
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if b.skip {
+			if i < 0 {
+				break
+			}
+			data = data[i+1:]
+			b.skip = false
+			continue
+		}
+
+		complete := i >= 0
+		if complete {
+			b.partial = append(b.partial, data[:i]...)
+			data = data[i+1:]
+		} else {
+			b.partial = append(b.partial, data...)
+			data = nil
+		}
+
+		if b.max > 0 && b.split {
+			for len(b.partial) > b.max {
+				b.lines = append(b.lines, b.partial[:b.max]...)
+				b.lines = append(b.lines, '\n')
+				b.partial = append(b.partial[:0], b.partial[b.max:]...)
+			}
+		} else if b.max > 0 && len(b.partial) > b.max {
+			if len(b.lines) > 0 {
+				send(b.lines, false)
+				b.lines = b.lines[:0]
+			}
+			send(b.partial[:b.max], true)
+			b.partial = b.partial[:0]
+			b.skip = !complete
+			continue
+		}
+
+		if complete {
+			b.lines = append(b.lines, b.partial...)
+			b.lines = append(b.lines, '\n')
+			b.partial = b.partial[:0]
+		}
+	}
+	if len(b.lines) > 0 {
+		send(b.lines, false)
+	}
+}
+
+// flush calls send with the incomplete last line.
+func (b *lineBuffer) flush(send func(data []byte, oversize bool)) {
+	if len(b.partial) > 0 {
+		send(b.partial, false)
+	}
+	b.reset()
+}
+
+// pending returns the bytes of the incomplete last line.
+func (b *lineBuffer) pending() int {
+	return len(b.partial)
+}
+
+func (b *lineBuffer) reset() {
+	b.partial = b.partial[:0]
+	b.skip = false
+}
+
+// lineOptions is the handling of the lines common to Reader and Batch.
+type lineOptions struct {
+	tag       string
+	multiline *Multiline
+	decoder   *charset.Decoder
+	maxSize   int
+	oversize  Oversize
+	marker    []byte
+	errorTag  string
+}
+
+func (o *lineOptions) newLineBuffer() *lineBuffer {
+	return newLineBuffer(o.maxSize, o.oversize)
+}
+
+func (o *lineOptions) decode(data []byte) []byte {
+	if o.decoder == nil {
+		return data
+	}
+	return o.decoder.Decode(data)
+}
+
+// truncated returns the head of an oversize line with the marker.
+func (o *lineOptions) truncated(data []byte) []byte {
+	line := make([]byte, 0, len(data)+len(o.marker))
+	line = append(line, o.decode(data)...)
+	return append(line, o.marker...)
+}
+
+// readData returns the bytes of the chunk for Read.
+// The oversize lines are truncated, or dropped if diverted.
+func (o *lineOptions) readData(c chunk) []byte {
+	if !c.oversize {
+		return o.decode(c.data)
+	} else if o.oversize == Divert {
+		return nil
+	}
+	return append(o.truncated(c.data), '\n')
+}
+
+// emitChunks emits each line of the chunks as a record, or each event
+// joining the lines if multiline, until the chunks are closed.
+func (o *lineOptions) emitChunks(chunks <-chan chunk, e gigo.Emitter, logf func(string, ...interface{})) {
+	emit := func(tag, path string, data []byte) {
+		record := gigo.NewRecord(tag, data)
+		record.Set(pathKey, path)
+		if tag == o.errorTag {
+			record.Set(oversizeKey, true)
+		}
+		if err := e.Emit(record); err != nil {
+			logf("emit error %s", err)
+		}
+	}
+	// emitOversize emits the head of an oversize line or event decoded.
+	emitOversize := func(path string, data []byte) {
+		if o.oversize == Divert {
+			emit(o.errorTag, path, data)
+			return
+		}
+		line := make([]byte, 0, len(data)+len(o.marker))
+		line = append(line, data...)
+		emit(o.tag, path, append(line, o.marker...))
+	}
+
+	if o.multiline == nil {
+		for c := range chunks {
+			if c.oversize {
+				emitOversize(c.path, o.decode(c.data))
+				continue
+			}
+			for _, line := range bytes.Split(o.decode(c.data), lineEnd) {
+				if len(line) > 0 {
+					emit(o.tag, c.path, line)
+				}
+			}
+		}
+		return
+	}
+
+	// the max line size also limits the events joined
+	a := newAssembler(o.multiline, o.maxSize, o.oversize == Split, func(path string, data []byte) {
+		emit(o.tag, path, data)
+	}, emitOversize)
+	ticker := time.NewTicker(o.multiline.flushInterval / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case c, ok := <-chunks:
+			if !ok {
+				a.flushAll()
+				return
+			}
+			if c.oversize {
+				// the event before the oversize line is emitted first
+				a.flushPath(c.path)
+				emitOversize(c.path, o.decode(c.data))
+				continue
+			}
+			now := time.Now()
+			for _, line := range bytes.Split(o.decode(c.data), lineEnd) {
+				if len(line) > 0 {
+					a.add(c.path, line, now)
+				}
+			}
+		case now := <-ticker.C:
+			a.flush(now)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package in_tail
+
+import (
+	"errors"
+	"regexp"
+	"time"
+)
+
+const (
+	defaultFlushInterval = time.Second
+)
+
+var (
+	errNoMultilineRule = errors.New("in_tail: no multiline rule")
+)
+
+type MultilineConfig struct {
+	// Start matches the first line of an event.
+	// The lines not matching are appended to the previous line.
+	Start string
+
+	// Continue matches the lines appended to the previous line.
+	Continue string
+
+	// Indent appends the lines starting with a space or a tab
+	// to the previous line.
+	Indent bool
+
+	// FlushInterval is the time to wait for the next line before
+	// emitting the last event. Default is 1s.
+	FlushInterval time.Duration
+}
+
+// Multiline joins the lines of an event such as a stack trace.
+// A line continues the previous event if it matches Continue,
+// is indented with Indent, or does not match Start.
+type Multiline struct {
+	start         *regexp.Regexp
+	cont          *regexp.Regexp
+	indent        bool
+	flushInterval time.Duration
+}
+
+func NewMultiline(config MultilineConfig) (*Multiline, error) {
+	m := &Multiline{indent: config.Indent}
+	if config.Start != "" {
+		re, err := regexp.Compile(config.Start)
+		if err != nil {
+			return nil, err
+		}
+		m.start = re
+	}
+	if config.Continue != "" {
+		re, err := regexp.Compile(config.Continue)
+		if err != nil {
+			return nil, err
+		}
+		m.cont = re
+	}
+	if m.start == nil && m.cont == nil && !m.indent {
+		return nil, errNoMultilineRule
+	}
+	m.flushInterval = config.FlushInterval
+	if m.flushInterval <= 0 {
+		m.flushInterval = defaultFlushInterval
+	}
+	return m, nil
+}
+
+func (m *Multiline) continues(line []byte) bool {
+	if m.cont != nil && m.cont.Match(line) {
+		return true
+	}
+	if m.indent && len(line) > 0 && (line[0] == ' ' || line[0] == '\t') {
+		return true
+	}
+	if m.start != nil && !m.start.Match(line) {
+		return true
+	}
+	return false
+}
+
+// assembler holds the event being joined for each file.
+// An event longer than max is emitted in pieces of max if split,
+// otherwise its head is passed to oversize and the rest is discarded.
+type assembler struct {
+	m        *Multiline
+	max      int
+	split    bool
+	emit     func(path string, data []byte)
+	oversize func(path string, data []byte)
+	events   map[string]*event
+}
+
+type event struct {
+	data    []byte
+	updated time.Time
+
+	// skip is true while discarding the rest of an oversize event.
+	skip bool
+}
+
+func newAssembler(m *Multiline, max int, split bool, emit, oversize func(path string, data []byte)) *assembler {
+	return &assembler{
+		m:        m,
+		max:      max,
+		split:    split,
+		emit:     emit,
+		oversize: oversize,
+		events:   make(map[string]*event),
+	}
+}
+
+// add appends the line to the event of the path,
+// or emits the event and starts a new one.
+func (a *assembler) add(path string, line []byte, now time.Time) {
+	ev, ok := a.events[path]
+	if ok && a.m.continues(line) {
+		ev.updated = now
+		if !ev.skip {
+			ev.data = append(ev.data, '\n')
+			ev.data = append(ev.data, line...)
+			a.limit(path, ev)
+		}
+		return
+	}
+	a.flushPath(path)
+	data := make([]byte, len(line))
+	copy(data, line)
+	ev = &event{data: data, updated: now}
+	a.events[path] = ev
+	a.limit(path, ev)
+}
+
+// limit emits the head of the event if longer than max.
+func (a *assembler) limit(path string, ev *event) {
+	if a.max <= 0 || len(ev.data) <= a.max {
+		return
+	}
+	if a.split {
+		for len(ev.data) > a.max {
+			a.emit(path, ev.data[:a.max:a.max])
+			ev.data = append([]byte(nil), ev.data[a.max:]...)
+		}
+		return
+	}
+	a.oversize(path, ev.data[:a.max])
+	ev.data = nil
+	ev.skip = true
+}
+
+// flush emits the events not updated for the flush interval.
+func (a *assembler) flush(now time.Time) {
+	for path, ev := range a.events {
+		if now.Sub(ev.updated) >= a.m.flushInterval {
+			a.flushPath(path)
+		}
+	}
+}
+
+// flushPath emits the event of the path if any.
+func (a *assembler) flushPath(path string) {
+	ev, ok := a.events[path]
+	if !ok {
+		return
+	}
+	if !ev.skip {
+		a.emit(path, ev.data)
+	}
+	delete(a.events, path)
+}
+
+// flushAll emits all the events.
+func (a *assembler) flushAll() {
+	for path := range a.events {
+		a.flushPath(path)
+	}
+}
@@ -0,0 +1,232 @@
+package in_tail
+
+import (
+	"bufio"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSyncInterval = time.Second * 5
+
+	// headSize is the length of the head identifying a file.
+	headSize = 1024
+)
+
+// position is where reading a file has reached.
+// Head is the hash of the head of the file up to Offset, identifying the
+// file once rotated and compressed. It is 0 if unknown.
+type position struct {
+	Inode  uint64
+	Offset int64
+	Head   uint64
+}
+
+// headLength returns the length of the head hashed for the offset.
+func headLength(offset int64) int64 {
+	if offset < headSize {
+		return offset
+	}
+	return headSize
+}
+
+// hashHead returns the hash of the first n bytes read from r,
+// and false if r is shorter.
+func hashHead(r io.Reader, n int64) (uint64, bool) {
+	h := fnv.New64a()
+	if c, err := io.CopyN(h, r, n); err != nil || c != n {
+		return 0, false
+	}
+	return h.Sum64(), true
+}
+
+// positionFile persists the positions of files.
+// Each line is "path\toffset\tinode" with hexadecimal numbers,
+// compatible with the pos_file of fluentd, followed by "\thead" if known.
+type positionFile struct {
+	r *Reader
+
+	path    string
+	mu      sync.Mutex
+	entries map[string]position
+	dirty   bool
+	version uint64 // counts the changes of entries
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func openPositionFile(r *Reader, path string) (*positionFile, error) {
+	p := &positionFile{
+		r:       r,
+		path:    path,
+		entries: make(map[string]position),
+	}
+	if err := p.load(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *positionFile) load() error {
+	f, err := os.Open(p.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		parts := strings.Split(scanner.Text(), "\t")
+		if len(parts) != 3 && len(parts) != 4 {
+			continue
+		}
+		offset, err := strconv.ParseInt(parts[1], 16, 64)
+		if err != nil {
+			continue
+		}
+		inode, err := strconv.ParseUint(parts[2], 16, 64)
+		if err != nil {
+			continue
+		}
+		var head uint64
+		if len(parts) == 4 {
+			if head, err = strconv.ParseUint(parts[3], 16, 64); err != nil {
+				continue
+			}
+		}
+		p.entries[parts[0]] = position{Inode: inode, Offset: offset, Head: head}
+	}
+	return scanner.Err()
+}
+
+func (p *positionFile) get(path string) (position, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pos, ok := p.entries[path]
+	return pos, ok
+}
+
+func (p *positionFile) set(path string, pos position) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.entries[path] != pos {
+		p.entries[path] = pos
+		p.dirty = true
+		p.version++
+	}
+}
+
+func (p *positionFile) remove(path string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.entries[path]; ok {
+		delete(p.entries, path)
+		p.dirty = true
+		p.version++
+	}
+}
+
+// sync writes the positions to a temporary file and renames it,
+// so the file is not broken by a crash while writing.
+func (p *positionFile) sync() error {
+	p.mu.Lock()
+	if !p.dirty {
+		p.mu.Unlock()
+		return nil
+	}
+	var buf strings.Builder
+	for path, pos := range p.entries {
+		fmt.Fprintf(&buf, "%s\t%016x\t%016x", path, pos.Offset, pos.Inode)
+		if pos.Head != 0 {
+			fmt.Fprintf(&buf, "\t%016x", pos.Head)
+		}
+		buf.WriteByte('\n')
+	}
+	version := p.version
+	p.mu.Unlock()
+
+	if err := p.write(buf.String()); err != nil {
+		return err
+	}
+
+	// the positions changed while writing are written by the next sync
+	p.mu.Lock()
+	if p.version == version {
+		p.dirty = false
+	}
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *positionFile) write(data string) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(p.path), filepath.Base(p.path))
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.WriteString(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), p.path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// start syncs the positions periodically until close.
+func (p *positionFile) start(interval time.Duration) {
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				if err := p.sync(); err != nil {
+					p.r.Errorf("position sync error %s", err)
+				}
+			}
+		}
+	}()
+}
+
+// close stops syncing periodically and syncs the positions.
+func (p *positionFile) close() error {
+	if p.stop != nil {
+		close(p.stop)
+		<-p.done
+		p.stop = nil
+	}
+	if err := p.sync(); err != nil {
+		p.r.Errorf("position sync error %s", err)
+		return err
+	}
+	return nil
+}
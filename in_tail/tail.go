@@ -1,110 +1,325 @@
 package in_tail
 
 import (
-	"bufio"
 	"io"
-	"os/exec"
+	"sync"
+	"time"
 
 	"github.com/najeira/gigo"
+	"github.com/najeira/gigo/charset"
 )
 
 const (
-	pluginName = "in_tail"
+	pluginName      = "in_tail"
+	pathKey         = "path"
+	defaultInterval = time.Millisecond * 250
 )
 
 var (
 	_ io.ReadCloser = (*Reader)(nil)
+	_ gigo.Input    = (*Reader)(nil)
+
+	lineEnd = []byte{'\n'}
 )
 
+func init() {
+	gigo.RegisterInput("tail", newInput)
+}
+
 type Config struct {
+	// File is a path or a glob pattern such as "/var/log/app/*.log".
 	File string
+	Tag  string
+
+	// PollInterval is the interval to check the file for new data,
+	// rotation and truncation. Default is 250ms.
+	PollInterval time.Duration
+
+	// PosFile records the positions of files to resume on Open.
+	PosFile string
+
+	// SyncInterval is the interval to sync PosFile. Default is 5s.
+	SyncInterval time.Duration
+
+	// ReadFromHead reads a file not in PosFile from the head.
+	// Otherwise only the lines written after Open are read.
+	// Files found after Open are always read from the head.
+	ReadFromHead bool
+
+	// RefreshInterval is the interval to find new files matching
+	// the glob pattern. Default is 60s.
+	RefreshInterval time.Duration
+
+	// RemoveWait is the time to keep following a file removed.
+	// Default is 5s.
+	RemoveWait time.Duration
+
+	// ReadRotated reads the rest of the file rotated while stopped,
+	// such as "app.log.1" or "app.log.1.gz", when resuming from PosFile.
+	ReadRotated bool
+
+	// Multiline joins the lines of an event into a record on Start.
+	// Each line is a record if nil.
+	Multiline *Multiline
+
+	// Decoder converts the lines to UTF-8 if not nil.
+	Decoder *charset.Decoder
+
+	// MaxLineSize is the max bytes of a line, and of an event joined
+	// by Multiline. No limit if 0.
+	MaxLineSize int
+
+	// Oversize is the handling of the lines longer than MaxLineSize.
+	Oversize Oversize
+
+	// TruncateMarker is appended to the truncated lines.
+	// Default is "...(truncated)".
+	TruncateMarker string
+
+	// ErrorTag is the tag of the diverted lines.
+	// Default is Tag + ".oversize".
+	ErrorTag string
 }
 
 type Reader struct {
 	gigo.Mixin
 
-	file    string
-	cmd     *exec.Cmd
-	outPipe io.ReadCloser
+	lineOptions
+
+	file            string
+	interval        time.Duration
+	posFile         string
+	syncInterval    time.Duration
+	readFromHead    bool
+	refreshInterval time.Duration
+	removeWait      time.Duration
+	readRotated     bool
+
+	mu      sync.Mutex // guards watcher
+	watcher *watcher
+	chunks  chan chunk
+	pending []byte
+	done    chan struct{}
 }
 
 func New(config Config) *Reader {
 	r := &Reader{}
 	r.Name = pluginName
 	r.file = config.File
+	r.tag = config.Tag
+	if r.tag == "" {
+		r.tag = pluginName
+	}
+	r.interval = config.PollInterval
+	if r.interval <= 0 {
+		r.interval = defaultInterval
+	}
+	r.posFile = config.PosFile
+	r.syncInterval = config.SyncInterval
+	if r.syncInterval <= 0 {
+		r.syncInterval = defaultSyncInterval
+	}
+	r.readFromHead = config.ReadFromHead
+	r.refreshInterval = config.RefreshInterval
+	if r.refreshInterval <= 0 {
+		r.refreshInterval = defaultRefreshInterval
+	}
+	r.removeWait = config.RemoveWait
+	if r.removeWait <= 0 {
+		r.removeWait = defaultRemoveWait
+	}
+	r.readRotated = config.ReadRotated
+	r.multiline = config.Multiline
+	r.decoder = config.Decoder
+	r.maxSize = config.MaxLineSize
+	r.oversize = config.Oversize
+	r.marker = []byte(config.TruncateMarker)
+	if config.TruncateMarker == "" {
+		r.marker = []byte(defaultTruncateMarker)
+	}
+	r.errorTag = config.ErrorTag
+	if r.errorTag == "" {
+		r.errorTag = r.tag + "." + oversizeKey
+	}
 	return r
 }
 
-func (r *Reader) Open() error {
-	r.cmd = exec.Command("tail", "-n", "0", "-F", r.file)
-
-	outPipe, err := r.cmd.StdoutPipe()
+func newInput(config gigo.PluginConfig) (gigo.Input, error) {
+	if err := config.Require("file"); err != nil {
+		return nil, err
+	}
+	interval, err := config.Duration("poll_interval", defaultInterval)
 	if err != nil {
-		r.Errorf("stdout error %s", err)
-		return err
+		return nil, err
 	}
-	r.outPipe = outPipe
-
-	errPipe, err := r.cmd.StderrPipe()
+	syncInterval, err := config.Duration("pos_sync_interval", defaultSyncInterval)
 	if err != nil {
-		r.Errorf("stderr error %s", err)
-		return err
+		return nil, err
 	}
-
-	if err := r.cmd.Start(); err != nil {
-		r.Errorf("start error %s", err)
-		return err
+	refreshInterval, err := config.Duration("refresh_interval", defaultRefreshInterval)
+	if err != nil {
+		return nil, err
 	}
-
-	go r.scanErrPipe(errPipe)
-
-	r.Debugf("tail -n 0 -F %s", r.file)
-	return nil
+	removeWait, err := config.Duration("remove_wait", defaultRemoveWait)
+	if err != nil {
+		return nil, err
+	}
+	multiline, err := newMultiline(config)
+	if err != nil {
+		return nil, err
+	}
+	decoder, err := charset.New(config)
+	if err != nil {
+		return nil, err
+	}
+	oversize, err := ParseOversize(config.String("oversize", ""))
+	if err != nil {
+		return nil, err
+	}
+	return New(Config{
+		File:            config.String("file", ""),
+		Tag:             config.String("tag", ""),
+		PollInterval:    interval,
+		PosFile:         config.String("pos_file", ""),
+		SyncInterval:    syncInterval,
+		ReadFromHead:    config.Bool("read_from_head", false),
+		RefreshInterval: refreshInterval,
+		RemoveWait:      removeWait,
+		ReadRotated:     config.Bool("read_rotated", false),
+		Multiline:       multiline,
+		Decoder:         decoder,
+		MaxLineSize:     int(config.Int("max_line_size", 0)),
+		Oversize:        oversize,
+		TruncateMarker:  config.String("truncate_marker", ""),
+		ErrorTag:        config.String("error_tag", ""),
+	}), nil
 }
 
-func (r *Reader) Read(buf []byte) (int, error) {
-	n, err := r.outPipe.Read(buf)
+func newMultiline(config gigo.PluginConfig) (*Multiline, error) {
+	if !config.Has("multiline_start") && !config.Has("multiline_continue") &&
+		!config.Has("multiline_indent") {
+		return nil, nil
+	}
+	flushInterval, err := config.Duration("multiline_flush_interval", defaultFlushInterval)
 	if err != nil {
-		r.Infof("read %s", err)
-	} else {
-		r.Debugf("read %d bytes", n)
+		return nil, err
 	}
-	return n, err
+	return NewMultiline(MultilineConfig{
+		Start:         config.String("multiline_start", ""),
+		Continue:      config.String("multiline_continue", ""),
+		Indent:        config.Bool("multiline_indent", false),
+		FlushInterval: flushInterval,
+	})
 }
 
-func (r *Reader) scanErrPipe(pipe io.Reader) error {
-	r.Debug("scan stderr")
+// Open starts following the files from the positions in PosFile,
+// or from the end unless ReadFromHead. Read must be called until io.EOF,
+// as following blocks while the lines read are not consumed, and PosFile
+// is synced only after the remaining lines are read.
+func (r *Reader) Open() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.watcher != nil {
+		return gigo.ErrAlreadyStarted
+	}
 
-	scanner := bufio.NewScanner(pipe)
-	for scanner.Scan() {
-		r.Infof(scanner.Text())
+	var positions *positionFile
+	if r.posFile != "" {
+		p, err := openPositionFile(r, r.posFile)
+		if err != nil {
+			r.Errorf("position file error %s", err)
+			return err
+		}
+		positions = p
 	}
 
-	if err := scanner.Err(); err != nil {
-		r.Infof("scan error %s", err)
+	w := newWatcher(r, positions)
+	if err := w.open(!r.readFromHead); err != nil {
+		r.Errorf("open error %s", err)
 		return err
 	}
+	if positions != nil {
+		positions.start(r.syncInterval)
+	}
+
+	r.watcher = w
+	r.chunks = w.out
+	r.pending = nil
+	go w.run()
 
-	r.Debug("scan end")
+	r.Debugf("watch %s", r.file)
 	return nil
 }
 
+// Read reads the lines of the files. Lines of different files
+// are not mixed, but the order between files is not defined.
+func (r *Reader) Read(buf []byte) (int, error) {
+	if r.chunks == nil {
+		return 0, gigo.ErrNotStarted
+	}
+	for len(r.pending) <= 0 {
+		c, ok := <-r.chunks
+		if !ok {
+			r.Info("read EOF")
+			return 0, io.EOF
+		}
+		r.pending = r.readData(c)
+	}
+	n := copy(buf, r.pending)
+	r.pending = r.pending[n:]
+	r.Debugf("read %d bytes", n)
+	return n, nil
+}
+
+// Close stops following the files. Read returns io.EOF
+// after the data remaining in the files, then PosFile is synced.
 func (r *Reader) Close() error {
-	if r.cmd == nil || r.cmd.Process == nil {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.watcher == nil {
 		return nil
 	}
+	close(r.watcher.stop)
+	r.watcher = nil
+	r.Debugf("close %s", r.file)
+	return nil
+}
 
-	if err := r.cmd.Process.Kill(); err != nil {
-		r.Infof("kill error %s", err)
+// Start opens the files and emits each line as a record
+// with the path to e until Stop.
+func (r *Reader) Start(e gigo.Emitter) error {
+	if r.done != nil {
+		return gigo.ErrAlreadyStarted
+	}
+	if err := r.Open(); err != nil {
 		return err
 	}
+	r.done = make(chan struct{})
+	go r.emitLines(e, r.done)
+	return nil
+}
 
-	r.Debugf("kill %d", r.cmd.Process.Pid)
+func (r *Reader) emitLines(e gigo.Emitter, done chan struct{}) {
+	defer close(done)
+	r.emitChunks(r.chunks, e, r.Infof)
+}
+
+// Stop closes the files and waits for the remaining lines to be emitted.
+func (r *Reader) Stop() error {
+	if r.done == nil {
+		return gigo.ErrNotStarted
+	}
+	err := r.Close()
+	<-r.done
+	r.done = nil
+	return err
+}
 
-	if err := r.cmd.Wait(); err != nil {
-		// err will be "signal: interrupt"
-		r.Debugf("end %s", err)
+func (r *Reader) Health() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.watcher == nil {
+		return gigo.ErrNotStarted
 	}
-	r.cmd = nil
 	return nil
 }
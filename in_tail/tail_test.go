@@ -1,10 +1,18 @@
 package in_tail
 
 import (
+	"compress/gzip"
+	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/najeira/gigo"
 )
 
 func TestTail(t *testing.T) {
@@ -69,3 +77,649 @@ func TestTail(t *testing.T) {
 		t.Errorf("invalid emit: %s", rets)
 	}
 }
+
+func TestStartStop(t *testing.T) {
+	f, err := ioutil.TempFile(os.TempDir(), "")
+	if err != nil {
+		t.Error(err)
+	}
+
+	path := f.Name()
+
+	defer func() {
+		f.Close()
+		os.Remove(path)
+	}()
+
+	var lines []string
+	emitter := gigo.EmitterFunc(func(record *gigo.Record) error {
+		if record.Tag != "test" {
+			t.Errorf("invalid tag: %s", record.Tag)
+		}
+		if p := record.GetString("path"); p != path {
+			t.Errorf("invalid path: %s", p)
+		}
+		lines = append(lines, string(record.Raw))
+		return nil
+	})
+
+	p := New(Config{File: path, Tag: "test"})
+	if err := p.Start(emitter); err != nil {
+		t.Error(err)
+	}
+	if err := p.Health(); err != nil {
+		t.Error(err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	_, err = f.WriteString("this\nis\ntest\n")
+	if err != nil {
+		t.Error(err)
+	}
+
+	if err = f.Sync(); err != nil {
+		t.Error(err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	// Health is safe while stopping
+	healthDone := make(chan struct{})
+	go func() {
+		defer close(healthDone)
+		for p.Health() == nil {
+		}
+	}()
+
+	if err = p.Stop(); err != nil {
+		t.Error(err)
+	}
+	<-healthDone
+	if err := p.Health(); err != gigo.ErrNotStarted {
+		t.Errorf("invalid health: %v", err)
+	}
+
+	if rets := strings.Join(lines, ","); rets != "this,is,test" {
+		t.Errorf("invalid emit: %s", rets)
+	}
+}
+
+func readAllAsync(r io.Reader) <-chan string {
+	ch := make(chan string, 1)
+	go func() {
+		ret, _ := ioutil.ReadAll(r)
+		ch <- string(ret)
+	}()
+	return ch
+}
+
+func TestRotate(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.log")
+	if err := ioutil.WriteFile(path, []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := New(Config{File: path, PollInterval: 5 * time.Millisecond})
+	if err := p.Open(); err != nil {
+		t.Fatal(err)
+	}
+	retCh := readAllAsync(p)
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("a\n")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("b\n")
+	f.Close()
+
+	if err := ioutil.WriteFile(path, []byte("c\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	if err := p.Close(); err != nil {
+		t.Error(err)
+	}
+	if rets := <-retCh; rets != "a\nb\nc\n" {
+		t.Errorf("invalid read: %q", rets)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	f, err := ioutil.TempFile(os.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := f.Name()
+	defer func() {
+		f.Close()
+		os.Remove(path)
+	}()
+
+	p := New(Config{File: path, PollInterval: 5 * time.Millisecond})
+	if err := p.Open(); err != nil {
+		t.Fatal(err)
+	}
+	retCh := readAllAsync(p)
+
+	f.WriteString("abcdef\n")
+	time.Sleep(20 * time.Millisecond)
+
+	if err := f.Truncate(0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt([]byte("g\n"), 0); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	if err := p.Close(); err != nil {
+		t.Error(err)
+	}
+	if rets := <-retCh; rets != "abcdef\ng\n" {
+		t.Errorf("invalid read: %q", rets)
+	}
+}
+
+func TestPosFile(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.log")
+	posFile := filepath.Join(dir, "test.pos")
+	if err := ioutil.WriteFile(path, []byte("a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	read := func() string {
+		p := New(Config{
+			File:         path,
+			PollInterval: 5 * time.Millisecond,
+			PosFile:      posFile,
+			ReadFromHead: true,
+		})
+		if err := p.Open(); err != nil {
+			t.Fatal(err)
+		}
+		retCh := readAllAsync(p)
+		time.Sleep(20 * time.Millisecond)
+		if err := p.Close(); err != nil {
+			t.Error(err)
+		}
+		return <-retCh
+	}
+
+	if rets := read(); rets != "a\n" {
+		t.Errorf("invalid read: %q", rets)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("b\n")
+	f.Close()
+
+	if rets := read(); rets != "b\n" {
+		t.Errorf("invalid read: %q", rets)
+	}
+
+	pos, err := ioutil.ReadFile(posFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(pos), path+"\t0000000000000004\t") {
+		t.Errorf("invalid position: %q", pos)
+	}
+
+	// the positions failed to sync are synced again
+	sub := filepath.Join(dir, "sub")
+	pf, err := openPositionFile(nil, filepath.Join(sub, "test.pos"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pf.set(path, position{Inode: 1, Offset: 2})
+	if err := pf.sync(); err == nil {
+		t.Error("no error without the directory")
+	}
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := pf.sync(); err != nil {
+		t.Error(err)
+	}
+	if pos, err := ioutil.ReadFile(pf.path); err != nil {
+		t.Error(err)
+	} else if ret := string(pos); ret != path+"\t0000000000000002\t0000000000000001\n" {
+		t.Errorf("invalid position: %q", ret)
+	}
+
+	// the head is saved and loaded
+	pf.set(path, position{Inode: 1, Offset: 2, Head: 3})
+	if err := pf.sync(); err != nil {
+		t.Error(err)
+	}
+	pf, err = openPositionFile(nil, pf.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos, _ := pf.get(path); pos != (position{Inode: 1, Offset: 2, Head: 3}) {
+		t.Errorf("invalid position: %v", pos)
+	}
+}
+
+func TestGlob(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a := filepath.Join(dir, "a.log")
+	b := filepath.Join(dir, "b.log")
+	c := filepath.Join(dir, "c.log")
+	if err := ioutil.WriteFile(a, []byte("a1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(b, []byte("b1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "x.txt"), []byte("x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	lines := make(map[string]string)
+	emitter := gigo.EmitterFunc(func(record *gigo.Record) error {
+		mu.Lock()
+		defer mu.Unlock()
+		path := record.GetString("path")
+		lines[path] += string(record.Raw) + ","
+		return nil
+	})
+
+	p := New(Config{
+		File:            filepath.Join(dir, "*.log"),
+		PollInterval:    5 * time.Millisecond,
+		ReadFromHead:    true,
+		RefreshInterval: 10 * time.Millisecond,
+		RemoveWait:      10 * time.Millisecond,
+	})
+	if err := p.Start(emitter); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(c, []byte("c1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	if err := os.Remove(b); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	if err := p.Stop(); err != nil {
+		t.Error(err)
+	}
+
+	expected := map[string]string{a: "a1,", b: "b1,", c: "c1,"}
+	if len(lines) != len(expected) {
+		t.Errorf("invalid paths: %v", lines)
+	}
+	for path, rets := range expected {
+		if lines[path] != rets {
+			t.Errorf("invalid emit %s: %q", path, lines[path])
+		}
+	}
+}
+
+func TestMultiline(t *testing.T) {
+	tests := []struct {
+		config MultilineConfig
+		input  string
+		output []string
+	}{
+		{
+			MultilineConfig{Start: `^\d{4}-`},
+			"2017-01-01 error\n\tat Foo\n\tat Bar\n2017-01-02 ok\n",
+			[]string{"2017-01-01 error\n\tat Foo\n\tat Bar", "2017-01-02 ok"},
+		},
+		{
+			MultilineConfig{Continue: `^(goroutine|\s)`},
+			"panic: oops\ngoroutine 1\n\tmain.go:10\nnext\n",
+			[]string{"panic: oops\ngoroutine 1\n\tmain.go:10", "next"},
+		},
+		{
+			MultilineConfig{Indent: true},
+			"a\n  b\nc\n\td\n",
+			[]string{"a\n  b", "c\n\td"},
+		},
+	}
+
+	for _, test := range tests {
+		test.config.FlushInterval = 20 * time.Millisecond
+		m, err := NewMultiline(test.config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		f, err := ioutil.TempFile(os.TempDir(), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := f.Name()
+
+		var mu sync.Mutex
+		var events []string
+		emitter := gigo.EmitterFunc(func(record *gigo.Record) error {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, string(record.Raw))
+			return nil
+		})
+
+		p := New(Config{File: path, PollInterval: 5 * time.Millisecond, Multiline: m})
+		if err := p.Start(emitter); err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString(test.input)
+
+		// the last event is emitted after the flush interval
+		time.Sleep(100 * time.Millisecond)
+		mu.Lock()
+		rets := strings.Join(events, "|")
+		mu.Unlock()
+		if expected := strings.Join(test.output, "|"); rets != expected {
+			t.Errorf("invalid emit: %q, expected %q", rets, expected)
+		}
+
+		if err := p.Stop(); err != nil {
+			t.Error(err)
+		}
+		f.Close()
+		os.Remove(path)
+	}
+
+	if _, err := NewMultiline(MultilineConfig{}); err == nil {
+		t.Error("no error without rules")
+	}
+}
+
+func TestReadRotated(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.log")
+	posFile := filepath.Join(dir, "test.pos")
+	if err := ioutil.WriteFile(path, []byte("a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	read := func() string {
+		p := New(Config{
+			File:         path,
+			PollInterval: 5 * time.Millisecond,
+			PosFile:      posFile,
+			ReadFromHead: true,
+			ReadRotated:  true,
+		})
+		if err := p.Open(); err != nil {
+			t.Fatal(err)
+		}
+		retCh := readAllAsync(p)
+		time.Sleep(20 * time.Millisecond)
+		if err := p.Close(); err != nil {
+			t.Error(err)
+		}
+		return <-retCh
+	}
+
+	if rets := read(); rets != "a\n" {
+		t.Errorf("invalid read: %q", rets)
+	}
+
+	// rotated and compressed while stopped
+	writeGzip := func(name, data string, modTime time.Time) {
+		gz, err := os.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := gzip.NewWriter(gz)
+		w.Write([]byte(data))
+		w.Close()
+		gz.Close()
+		if err := os.Chtimes(name, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+	writeGzip(path+".2.gz", "a\nb\n", now.Add(-time.Hour))
+	// shorter than the position
+	writeGzip(path+".1.gz", "z", now)
+	// as long as the position, but another file
+	writeGzip(path+".0.gz", "y\nyy\n", now)
+	// older in the rotation order, even though modified later
+	writeGzip(path+".3.gz", "a\nx\n", now.Add(time.Hour))
+	// create before removing not to reuse the inode
+	if err := ioutil.WriteFile(path+".new", []byte("c\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(path+".new", path); err != nil {
+		t.Fatal(err)
+	}
+
+	if rets := read(); rets != "b\nc\n" {
+		t.Errorf("invalid read: %q", rets)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "a.log"), []byte("a1\na2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	gz, err := os.Create(filepath.Join(dir, "b.log.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw := gzip.NewWriter(gz)
+	gw.Write([]byte("b1\nb2"))
+	gw.Close()
+	gz.Close()
+
+	zf, err := os.Create(filepath.Join(dir, "c.log.zst"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw, err := zstd.NewWriter(zf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw.Write([]byte("c1\n"))
+	zw.Close()
+	zf.Close()
+
+	var lines []string
+	emitter := gigo.EmitterFunc(func(record *gigo.Record) error {
+		lines = append(lines, filepath.Base(record.GetString("path"))+":"+string(record.Raw))
+		return nil
+	})
+
+	b := NewBatch(BatchConfig{Path: dir, Tag: "test"})
+	if err := b.Start(emitter); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-b.Done():
+	case <-time.After(time.Second):
+		t.Fatal("timeout")
+	}
+	if err := b.Stop(); err != nil {
+		t.Error(err)
+	}
+
+	expected := "a.log:a1,a.log:a2,b.log.gz:b1,b.log.gz:b2,c.log.zst:c1"
+	if rets := strings.Join(lines, ","); rets != expected {
+		t.Errorf("invalid emit: %s", rets)
+	}
+}
+
+func TestLineBuffer(t *testing.T) {
+	tests := []struct {
+		oversize Oversize
+		input    []string
+		output   string
+	}{
+		{Truncate, []string{"abc\nabcdefgh\nxy\n"}, "abc\n|!abcd|xy\n|"},
+		{Truncate, []string{"ab", "cdef", "gh", "ij\nk\n"}, "!abcd|k\n|"},
+		{Split, []string{"abc\nabcdefghij\nxy\n"}, "abc\nabcd\nefgh\nij\nxy\n|"},
+		{Split, []string{"abcde", "fghij", "kl\n"}, "abcd\n|efgh\n|ijkl\n|"},
+		{Divert, []string{"abcdefghij", "klm", "\nz"}, "!abcd|z|"},
+	}
+	for _, test := range tests {
+		var rets []string
+		send := func(data []byte, oversize bool) {
+			if oversize {
+				rets = append(rets, "!"+string(data))
+			} else {
+				rets = append(rets, string(data))
+			}
+		}
+		b := newLineBuffer(4, test.oversize)
+		for _, input := range test.input {
+			b.write([]byte(input), send)
+		}
+		b.flush(send)
+		if ret := strings.Join(rets, "|") + "|"; ret != test.output {
+			t.Errorf("%d: invalid lines %q, expected %q", test.oversize, ret, test.output)
+		}
+	}
+}
+
+func TestOversize(t *testing.T) {
+	tests := []struct {
+		oversize Oversize
+		output   string
+	}{
+		{Truncate, "test:a,test:bbbb[cut],test:c"},
+		{Split, "test:a,test:bbbb,test:bbbb,test:bb,test:c"},
+		{Divert, "test:a,test.oversize:bbbb,test:c"},
+	}
+	for _, test := range tests {
+		f, err := ioutil.TempFile(os.TempDir(), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := f.Name()
+
+		var lines []string
+		emitter := gigo.EmitterFunc(func(record *gigo.Record) error {
+			lines = append(lines, record.Tag+":"+string(record.Raw))
+			return nil
+		})
+
+		p := New(Config{
+			File:           path,
+			Tag:            "test",
+			ReadFromHead:   true,
+			MaxLineSize:    4,
+			Oversize:       test.oversize,
+			TruncateMarker: "[cut]",
+		})
+		f.WriteString("a\n" + strings.Repeat("b", 10) + "\nc\n")
+		if err := p.Start(emitter); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Stop(); err != nil {
+			t.Error(err)
+		}
+		if rets := strings.Join(lines, ","); rets != test.output {
+			t.Errorf("%d: invalid emit %s, expected %s", test.oversize, rets, test.output)
+		}
+		f.Close()
+		os.Remove(path)
+	}
+}
+
+func TestOversizeMultiline(t *testing.T) {
+	tests := []struct {
+		oversize Oversize
+		output   string
+	}{
+		{Truncate, "test:a\n bb\n c[cut]|test:e\n f|test:longline[cut]|test:g"},
+		{Split, "test:a\n bb\n c|test:c\n dd|test:e\n f|test:longline|test:1234|test:g"},
+		{Divert, "test.oversize:a\n bb\n c|test:e\n f|test.oversize:longline|test:g"},
+	}
+	for _, test := range tests {
+		f, err := ioutil.TempFile(os.TempDir(), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := f.Name()
+
+		m, err := NewMultiline(MultilineConfig{Indent: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var mu sync.Mutex
+		var events []string
+		emitter := gigo.EmitterFunc(func(record *gigo.Record) error {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, record.Tag+":"+string(record.Raw))
+			return nil
+		})
+
+		p := New(Config{
+			File:           path,
+			Tag:            "test",
+			ReadFromHead:   true,
+			Multiline:      m,
+			MaxLineSize:    8,
+			Oversize:       test.oversize,
+			TruncateMarker: "[cut]",
+		})
+		// the joined event is limited, and the event before
+		// the oversize line is emitted first
+		f.WriteString("a\n bb\n cc\n dd\ne\n f\nlongline1234\ng\n")
+		if err := p.Start(emitter); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Stop(); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		rets := strings.Join(events, "|")
+		mu.Unlock()
+		if rets != test.output {
+			t.Errorf("%d: invalid emit %q, expected %q", test.oversize, rets, test.output)
+		}
+		f.Close()
+		os.Remove(path)
+	}
+}
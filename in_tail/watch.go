@@ -0,0 +1,179 @@
+package in_tail
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRefreshInterval = time.Second * 60
+	defaultRemoveWait      = time.Second * 5
+)
+
+// watcher finds the files matching a glob pattern periodically,
+// and runs a follower for each file.
+type watcher struct {
+	r *Reader
+
+	pattern   string
+	interval  time.Duration
+	wait      time.Duration
+	positions *positionFile
+	out       chan chunk
+
+	followers map[string]*follower
+	removed   map[string]time.Time
+	wg        sync.WaitGroup
+
+	stop chan struct{}
+}
+
+func newWatcher(r *Reader, positions *positionFile) *watcher {
+	return &watcher{
+		r:         r,
+		pattern:   r.file,
+		interval:  r.refreshInterval,
+		wait:      r.removeWait,
+		positions: positions,
+		out:       make(chan chunk, 16),
+		followers: make(map[string]*follower),
+		removed:   make(map[string]time.Time),
+		stop:      make(chan struct{}),
+	}
+}
+
+func hasMeta(path string) bool {
+	return strings.ContainsAny(path, `*?[\`)
+}
+
+// glob returns the paths to follow. A path without meta characters
+// is returned even if it does not exist yet, to wait for it.
+func (w *watcher) glob() ([]string, error) {
+	if !hasMeta(w.pattern) {
+		return []string{w.pattern}, nil
+	}
+	return filepath.Glob(w.pattern)
+}
+
+// open starts following the files matching at the moment.
+func (w *watcher) open(fromEnd bool) error {
+	paths, err := w.glob()
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if err := w.follow(path, fromEnd); err != nil {
+			w.close()
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *watcher) follow(path string, fromEnd bool) error {
+	f := newFollower(w.r, path, w.r.interval, w.positions)
+	if err := f.open(fromEnd); err != nil {
+		return err
+	}
+	w.followers[path] = f
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		f.run(w.out)
+	}()
+	w.r.Infof("follow %s", path)
+	return nil
+}
+
+func (w *watcher) unfollow(path string) {
+	f, ok := w.followers[path]
+	if !ok {
+		return
+	}
+	close(f.stop)
+	<-f.done
+	delete(w.followers, path)
+	delete(w.removed, path)
+	if w.positions != nil {
+		w.positions.remove(path)
+	}
+	w.r.Infof("unfollow %s", path)
+}
+
+// run refreshes the files until stop is closed,
+// then stops the followers and closes out.
+func (w *watcher) run() {
+	defer func() {
+		w.close()
+		close(w.out)
+	}()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.refresh()
+		}
+	}
+}
+
+// refresh follows the new files from the head, and unfollows
+// the files removed longer than the wait.
+func (w *watcher) refresh() {
+	if !hasMeta(w.pattern) {
+		return
+	}
+
+	paths, err := w.glob()
+	if err != nil {
+		w.r.Errorf("glob error %s", err)
+		return
+	}
+
+	found := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		found[path] = true
+		delete(w.removed, path)
+		if _, ok := w.followers[path]; !ok {
+			if err := w.follow(path, false); err != nil {
+				w.r.Errorf("follow error %s", err)
+			}
+		}
+	}
+
+	now := time.Now()
+	for path := range w.followers {
+		if found[path] {
+			continue
+		}
+		if _, err := os.Stat(path); err == nil {
+			// not matched but exists
+			continue
+		}
+		removedAt, ok := w.removed[path]
+		if !ok {
+			w.removed[path] = now
+		} else if now.Sub(removedAt) >= w.wait {
+			w.unfollow(path)
+		}
+	}
+}
+
+// close stops the followers and syncs the positions.
+func (w *watcher) close() {
+	for _, f := range w.followers {
+		close(f.stop)
+	}
+	w.wg.Wait()
+	w.followers = make(map[string]*follower)
+	if w.positions != nil {
+		w.positions.close()
+	}
+}
@@ -0,0 +1,42 @@
+package gigo
+
+// Logger is the logger given to plugins by their Config.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+var (
+	_ Logger = (*Mixin)(nil)
+	_ Logger = nopLogger{}
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Debugf(format string, args ...interface{}) {}
+func (nopLogger) Infof(format string, args ...interface{})  {}
+func (nopLogger) Warnf(format string, args ...interface{})  {}
+func (nopLogger) Errorf(format string, args ...interface{}) {}
+
+// NewLogger returns a logger writing the logs at lvl or more severe to fn.
+// lvl is a log_level such as "warn".
+func NewLogger(fn LogFunc, lvl string) Logger {
+	m := &Mixin{}
+	m.SetLogging(fn, lvl)
+	return m
+}
+
+// EnsureLogger returns l, or a logger discarding the logs if l is nil.
+func EnsureLogger(l Logger) Logger {
+	if l == nil {
+		return nopLogger{}
+	}
+	return l
+}
+
+// Debugf logs to l if l is not nil.
+func Debugf(l Logger, format string, args ...interface{}) {
+	EnsureLogger(l).Debugf(format, args...)
+}
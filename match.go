@@ -0,0 +1,109 @@
+package gigo
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Matcher matches tags with fluentd-style patterns.
+// "*" matches a single tag part, so "a.*" matches "a.b" but not "a.b.c".
+// "**" matches zero or more tag parts, so "a.**" matches "a", "a.b" and "a.b.c".
+// "{x,y}" matches x or y, so "a.{b,c}" matches "a.b" and "a.c".
+// Patterns separated by whitespace are alternatives.
+type Matcher struct {
+	pattern string
+	regexps []*regexp.Regexp
+}
+
+func NewMatcher(pattern string) (*Matcher, error) {
+	m := &Matcher{pattern: pattern}
+	for _, field := range strings.Fields(pattern) {
+		expanded, err := expandBraces(field)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range expanded {
+			re, err := regexp.Compile(patternToRegexp(p))
+			if err != nil {
+				return nil, err
+			}
+			m.regexps = append(m.regexps, re)
+		}
+	}
+	if len(m.regexps) <= 0 {
+		return nil, fmt.Errorf("gigo: empty match pattern")
+	}
+	return m, nil
+}
+
+func (m *Matcher) Match(tag string) bool {
+	for _, re := range m.regexps {
+		if re.MatchString(tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Matcher) String() string {
+	return m.pattern
+}
+
+// expandBraces expands "a.{b,c}" to "a.b" and "a.c".
+// Nested braces are not supported.
+func expandBraces(pattern string) ([]string, error) {
+	start := strings.IndexByte(pattern, '{')
+	if start < 0 {
+		if strings.IndexByte(pattern, '}') >= 0 {
+			return nil, fmt.Errorf("gigo: unbalanced braces in %s", pattern)
+		}
+		return []string{pattern}, nil
+	}
+	end := strings.IndexByte(pattern[start:], '}')
+	if end < 0 {
+		return nil, fmt.Errorf("gigo: unbalanced braces in %s", pattern)
+	}
+	end += start
+
+	var results []string
+	for _, alt := range strings.Split(pattern[start+1:end], ",") {
+		rest, err := expandBraces(pattern[:start] + alt + pattern[end+1:])
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, rest...)
+	}
+	return results, nil
+}
+
+func patternToRegexp(pattern string) string {
+	var buf strings.Builder
+	buf.WriteString("^")
+	parts := strings.Split(pattern, ".")
+	last := len(parts) - 1
+	for i, part := range parts {
+		if part == "**" {
+			if i == 0 && i == last {
+				buf.WriteString(".*")
+			} else if i == 0 {
+				// the next part follows without a separator
+				buf.WriteString(`(?:.*\.)?`)
+			} else {
+				buf.WriteString(`(?:\..*)?`)
+			}
+			continue
+		}
+		if i > 0 && !(i == 1 && parts[0] == "**") {
+			buf.WriteString(`\.`)
+		}
+		for j, s := range strings.Split(part, "*") {
+			if j > 0 {
+				buf.WriteString(`[^.]*`)
+			}
+			buf.WriteString(regexp.QuoteMeta(s))
+		}
+	}
+	buf.WriteString("$")
+	return buf.String()
+}
@@ -0,0 +1,57 @@
+package gigo
+
+import (
+	"testing"
+)
+
+func TestMatcher(t *testing.T) {
+	tests := []struct {
+		pattern string
+		tag     string
+		match   bool
+	}{
+		{"a", "a", true},
+		{"a", "b", false},
+		{"a", "a.b", false},
+		{"a.*", "a.b", true},
+		{"a.*", "a.b.c", false},
+		{"a.*", "a", false},
+		{"*.access", "web.access", true},
+		{"*.access", "web.error", false},
+		{"a.**", "a", true},
+		{"a.**", "a.b", true},
+		{"a.**", "a.b.c", true},
+		{"a.**", "ab", false},
+		{"**", "a.b.c", true},
+		{"**.c", "c", true},
+		{"**.c", "a.b.c", true},
+		{"**.c", "a.bc", false},
+		{"a.**.c", "a.c", true},
+		{"a.**.c", "a.b.c", true},
+		{"a.**.c", "a.b.d", false},
+		{"a.{b,c}", "a.b", true},
+		{"a.{b,c}", "a.c", true},
+		{"a.{b,c}", "a.d", false},
+		{"a b", "b", true},
+		{"a.b+", "a.b+", true},
+		{"a.b+", "a.bb", false},
+	}
+	for _, tt := range tests {
+		m, err := NewMatcher(tt.pattern)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if got := m.Match(tt.tag); got != tt.match {
+			t.Errorf("%s match %s: got %v", tt.pattern, tt.tag, got)
+		}
+	}
+}
+
+func TestMatcherError(t *testing.T) {
+	for _, pattern := range []string{"", "a.{b", "a.b}"} {
+		if _, err := NewMatcher(pattern); err == nil {
+			t.Errorf("%s: no error", pattern)
+		}
+	}
+}
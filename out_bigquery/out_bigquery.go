@@ -2,6 +2,7 @@ package out_bigquery
 
 import (
 	"fmt"
+	"io/ioutil"
 	"math/rand"
 	"time"
 
@@ -9,6 +10,10 @@ import (
 	"github.com/najeira/gigo"
 )
 
+func init() {
+	gigo.RegisterOutput("bigquery", newOutput)
+}
+
 type Config struct {
 	Project string
 	Dataset string
@@ -45,6 +50,24 @@ func New(config Config) *Output {
 	}
 }
 
+func newOutput(config gigo.PluginConfig) (gigo.Output, error) {
+	if err := config.Require("project", "dataset", "table", "email", "pem_file"); err != nil {
+		return nil, err
+	}
+	pem, err := ioutil.ReadFile(config.String("pem_file", ""))
+	if err != nil {
+		return nil, err
+	}
+	return New(Config{
+		Logger:  config.Logger(),
+		Project: config.String("project", ""),
+		Dataset: config.String("dataset", ""),
+		Table:   config.String("table", ""),
+		Email:   config.String("email", ""),
+		Pem:     pem,
+	}), nil
+}
+
 func (p *Output) Start() error {
 	gigo.Debugf(p.config.Logger, "out_bigquery: start")
 	if p.output != nil {
@@ -67,20 +90,27 @@ func (p *Output) Stop() error {
 		return fmt.Errorf("not started")
 	}
 	p.output.Close()
+	p.output = nil
 	return nil
 }
 
-func (p *Output) Emit(msg interface{}) error {
+func (p *Output) Health() error {
 	if p.output == nil {
-		return fmt.Errorf("not started")
+		return gigo.ErrNotStarted
 	}
+	return nil
+}
 
-	v, ok := msg.(map[string]interface{})
-	if !ok {
+func (p *Output) Emit(record *gigo.Record) error {
+	if p.output == nil {
 		return fmt.Errorf("not started")
 	}
 
-	return p.output.Add(genInsertId(10), v)
+	if len(record.Fields) <= 0 {
+		return fmt.Errorf("no fields")
+	}
+
+	return p.output.Add(genInsertId(10), record.Fields)
 }
 
 func genInsertId(length int) string {
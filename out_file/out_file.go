@@ -3,49 +3,204 @@ package out_file
 import (
 	"io"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/najeira/gigo"
+	"github.com/najeira/gigo/formatter"
 )
 
 var (
 	_ io.WriteCloser = (*Writer)(nil)
+	_ gigo.Output    = (*Writer)(nil)
 )
 
+func init() {
+	gigo.RegisterOutput("file", newOutput)
+}
+
 type Config struct {
-	Name   string
-	Flag   int
-	Perm   os.FileMode
-	Logger gigo.Logger
+	// Name is the path of the file, which may have the directives of
+	// strftime such as "/data/app-%Y%m%d-%H.log". The file is rotated
+	// when the path of the current time changes.
+	Name      string
+	Flag      int
+	Perm      os.FileMode
+	Formatter formatter.Formatter
+	Logger    gigo.Logger
+
+	// MaxSize rotates the file before it exceeds the bytes, renaming
+	// it to "Name.N". No limit if 0.
+	MaxSize int64
+
+	// MaxFiles and MaxAge remove the oldest rotated files over the count
+	// or older than the age. No limit if 0.
+	MaxFiles int
+	MaxAge   time.Duration
+
+	// Compress compresses the rotated files to ".gz".
+	Compress bool
 }
 
 type Writer struct {
-	file   *os.File
-	logger gigo.Logger
+	name      string
+	flag      int
+	perm      os.FileMode
+	file      *os.File
+	formatter formatter.Formatter
+	logger    gigo.Logger
+
+	maxSize  int64
+	maxFiles int
+	maxAge   time.Duration
+	compress bool
+	now      func() time.Time
+
+	// path is the current file, also read by the goroutines
+	// cleaning the rotated files.
+	mu   sync.Mutex
+	path string
+
+	size int64
+
+	cleanMu sync.Mutex
+	cleanWg sync.WaitGroup
 }
 
-func Open(config Config) (*Writer, error) {
+func New(config Config) *Writer {
 	w := &Writer{
-		logger: gigo.EnsureLogger(config.Logger),
+		name:      config.Name,
+		flag:      config.Flag,
+		perm:      config.Perm,
+		formatter: config.Formatter,
+		logger:    gigo.EnsureLogger(config.Logger),
+
+		maxSize:  config.MaxSize,
+		maxFiles: config.MaxFiles,
+		maxAge:   config.MaxAge,
+		compress: config.Compress,
+		now:      time.Now,
+	}
+	if w.formatter == nil {
+		w.formatter = formatter.NewRaw(formatter.RawConfig{})
+	}
+	return w
+}
+
+func newOutput(config gigo.PluginConfig) (gigo.Output, error) {
+	if err := config.Require("path"); err != nil {
+		return nil, err
+	}
+	flag := os.O_WRONLY | os.O_CREATE
+	if config.Bool("append", true) {
+		flag |= os.O_APPEND
+	} else {
+		flag |= os.O_TRUNC
+	}
+	// perm is an octal string such as "0644"
+	perm, err := strconv.ParseUint(config.String("perm", "0644"), 8, 32)
+	if err != nil {
+		return nil, err
+	}
+	f, err := formatter.New(config)
+	if err != nil {
+		return nil, err
+	}
+	maxAge, err := config.Duration("max_age", 0)
+	if err != nil {
+		return nil, err
 	}
-	if err := w.open(config.Name, config.Flag, config.Perm); err != nil {
+	return New(Config{
+		Logger:    config.Logger(),
+		Name:      config.String("path", ""),
+		Flag:      flag,
+		Perm:      os.FileMode(perm),
+		Formatter: f,
+		MaxSize:   config.Int("max_size", 0),
+		MaxFiles:  int(config.Int("max_files", 0)),
+		MaxAge:    maxAge,
+		Compress:  config.Bool("compress", false),
+	}), nil
+}
+
+func Open(config Config) (*Writer, error) {
+	w := New(config)
+	if err := w.open(strftime(w.name, w.now()), w.flag, w.perm); err != nil {
 		return nil, err
 	}
 	return w, nil
 }
 
+func (w *Writer) Start() error {
+	if w.file != nil {
+		return gigo.ErrAlreadyStarted
+	}
+	return w.open(strftime(w.name, w.now()), w.flag, w.perm)
+}
+
+func (w *Writer) Stop() error {
+	if w.file == nil {
+		return gigo.ErrNotStarted
+	}
+	return w.Close()
+}
+
+// Emit writes the record formatted by the formatter.
+func (w *Writer) Emit(record *gigo.Record) error {
+	if w.file == nil {
+		return gigo.ErrNotStarted
+	}
+	data, err := w.formatter.Format(record)
+	if err != nil {
+		w.logger.Warnf("out_file: format error %s", err)
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (w *Writer) Health() error {
+	if w.file == nil {
+		return gigo.ErrNotStarted
+	}
+	return nil
+}
+
 func (w *Writer) open(name string, flag int, perm os.FileMode) error {
+	if strings.Contains(w.name, "%") {
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			w.logger.Warnf("out_file: mkdir error %s", err)
+			return err
+		}
+	}
 	f, err := os.OpenFile(name, flag, perm)
 	if err != nil {
 		w.logger.Warnf("out_file: open error %s", err)
 		return err
 	}
+	var size int64
+	if info, err := f.Stat(); err == nil {
+		size = info.Size()
+	}
 	w.file = f
+	w.size = size
+	w.mu.Lock()
+	w.path = name
+	w.mu.Unlock()
 	w.logger.Infof("out_file: open file %s", name)
 	return nil
 }
 
+// Write writes msg to the file, rotating it before by the time or the size.
 func (w *Writer) Write(msg []byte) (int, error) {
+	if err := w.rotateIfNeeded(len(msg)); err != nil {
+		return 0, err
+	}
 	n, err := w.file.Write(msg)
+	w.size += int64(n)
 	if err != nil {
 		w.logger.Warnf("out_file: write error %s", err)
 	} else {
@@ -54,17 +209,23 @@ func (w *Writer) Write(msg []byte) (int, error) {
 	return n, err
 }
 
+// Close closes the file, and waits for the rotated files to be cleaned.
 func (w *Writer) Close() error {
-	if w.file == nil {
-		return nil
+	var err error
+	if w.file != nil {
+		err = w.closeFile(w.file)
+		w.file = nil
 	}
+	w.cleanWg.Wait()
+	return err
+}
 
-	if err := w.file.Sync(); err != nil {
+func (w *Writer) closeFile(f *os.File) error {
+	if err := f.Sync(); err != nil {
 		w.logger.Warnf("out_file: sync error %s", err)
 	}
 
-	err := w.file.Close()
-	w.file = nil
+	err := f.Close()
 	if err != nil {
 		w.logger.Warnf("out_file: close error %s", err)
 		return err
@@ -73,3 +234,96 @@ func (w *Writer) Close() error {
 	w.logger.Infof("out_file: close")
 	return nil
 }
+
+func (w *Writer) rotateIfNeeded(n int) error {
+	if w.file == nil {
+		return gigo.ErrNotStarted
+	}
+	path := strftime(w.name, w.now())
+	if path != w.path {
+		return w.rotate(path, false)
+	}
+	if w.maxSize > 0 && w.size > 0 && w.size+int64(n) > w.maxSize {
+		return w.rotate(path, true)
+	}
+	return nil
+}
+
+// rotate opens next and closes the current file. The current file is
+// renamed to "path.N" if bySize, as next is the same path.
+// On error, the current file is kept to write and rotated again
+// by the next write.
+func (w *Writer) rotate(next string, bySize bool) error {
+	current := w.path
+	prev := current
+	if bySize {
+		seg := nextSegment(prev)
+		if err := os.Rename(prev, seg); err != nil {
+			w.logger.Warnf("out_file: rename error %s", err)
+			return err
+		}
+		prev = seg
+	}
+
+	file := w.file
+	if err := w.open(next, w.flag, w.perm); err != nil {
+		if bySize {
+			if err := os.Rename(prev, current); err != nil {
+				w.logger.Warnf("out_file: rename error %s", err)
+			}
+		}
+		return err
+	}
+	w.logger.Infof("out_file: rotate %s", prev)
+
+	// a close error is logged, as the writes go on to next
+	w.closeFile(file)
+	w.clean(prev)
+	return nil
+}
+
+// clean compresses the rotated file and removes the old ones
+// in a goroutine.
+func (w *Writer) clean(rotated string) {
+	if !w.compress && w.maxFiles <= 0 && w.maxAge <= 0 {
+		return
+	}
+	w.cleanWg.Add(1)
+	go func() {
+		defer w.cleanWg.Done()
+		w.cleanMu.Lock()
+		defer w.cleanMu.Unlock()
+
+		if w.compress {
+			if err := gzipFile(rotated); err != nil {
+				w.logger.Warnf("out_file: compress error %s", err)
+			}
+		}
+		w.removeOld()
+	}()
+}
+
+func (w *Writer) removeOld() {
+	if w.maxFiles <= 0 && w.maxAge <= 0 {
+		return
+	}
+	w.mu.Lock()
+	current := w.path
+	w.mu.Unlock()
+
+	segs, err := segments(w.name, current)
+	if err != nil {
+		w.logger.Warnf("out_file: glob error %s", err)
+		return
+	}
+	expired := w.now().Add(-w.maxAge)
+	for i, seg := range segs {
+		if (w.maxFiles > 0 && i >= w.maxFiles) || (w.maxAge > 0 && seg.modTime.Before(expired)) {
+			if err := os.Remove(seg.name); err != nil {
+				w.logger.Warnf("out_file: remove error %s", err)
+			} else {
+				w.logger.Infof("out_file: remove %s", seg.name)
+			}
+		}
+	}
+}
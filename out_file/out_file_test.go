@@ -1,10 +1,15 @@
 package out_file
 
 import (
+	"compress/gzip"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/najeira/gigo/testutil"
 )
@@ -60,3 +65,154 @@ func TestReader(t *testing.T) {
 		t.Errorf("invalid error: %s", errs)
 	}
 }
+
+func TestStrftime(t *testing.T) {
+	tm := time.Date(2017, 2, 3, 4, 5, 6, 0, time.UTC)
+	tests := []struct {
+		layout string
+		output string
+	}{
+		{"/data/app.log", "/data/app.log"},
+		{"/data/app-%Y%m%d-%H.log", "/data/app-20170203-04.log"},
+		{"%y/%j/%M%S%%%q", "17/034/0506%%q"},
+	}
+	for _, test := range tests {
+		if ret := strftime(test.layout, tm); ret != test.output {
+			t.Errorf("%s: invalid %s", test.layout, ret)
+		}
+	}
+}
+
+func TestRotate(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	l := testutil.Logger{}
+	w := New(Config{
+		Logger:   &l,
+		Name:     filepath.Join(dir, "%Y", "app-%H.log"),
+		Flag:     os.O_WRONLY | os.O_CREATE | os.O_APPEND,
+		Perm:     0644,
+		MaxSize:  10,
+		MaxFiles: 2,
+		Compress: true,
+	})
+	// the clock is also read by the goroutines cleaning
+	var mu sync.Mutex
+	now := time.Date(2017, 1, 1, 10, 0, 0, 0, time.Local)
+	w.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	advance := func(d time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		now = now.Add(d)
+	}
+	if err := w.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// not a segment to remove
+	year := filepath.Join(dir, "2017")
+	if err := ioutil.WriteFile(filepath.Join(year, "app-09.log.bak"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	// the directory of 2018 fails to be made
+	if err := ioutil.WriteFile(filepath.Join(dir, "2018"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(s string) {
+		if _, err := w.Write([]byte(s)); err != nil {
+			t.Error(err)
+		}
+		// the rotated files are ordered by the modified time
+		time.Sleep(time.Millisecond * 10)
+	}
+	write("12345\n")
+	write("67890\n") // by size
+	advance(time.Hour)
+	write("abc\n") // by time
+	write("defgh\n")
+	advance(time.Hour)
+	write("x\n")
+
+	// the current file is kept on the error of rotating
+	advance(time.Hour * 24 * 365)
+	if _, err := w.Write([]byte("lost\n")); err == nil {
+		t.Error("no error for rotating to 2018")
+	}
+	advance(-time.Hour * 24 * 365)
+	write("y\n")
+	if err := w.Close(); err != nil {
+		t.Error(err)
+	}
+
+	names, err := filepath.Glob(filepath.Join(year, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range names {
+		names[i] = filepath.Base(name)
+	}
+	if ret := strings.Join(names, ","); ret != "app-09.log.bak,app-10.log.gz,app-11.log.gz,app-12.log" {
+		t.Errorf("invalid files %s", ret)
+	}
+
+	tests := map[string]string{
+		"app-10.log.gz": "67890\n",
+		"app-11.log.gz": "abc\ndefgh\n",
+		"app-12.log":    "x\ny\n",
+	}
+	for name, content := range tests {
+		f, err := os.Open(filepath.Join(year, name))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		var r io.Reader = f
+		if strings.HasSuffix(name, ".gz") {
+			if r, err = gzip.NewReader(f); err != nil {
+				t.Error(err)
+			}
+		}
+		if data, err := ioutil.ReadAll(r); err != nil {
+			t.Error(err)
+		} else if string(data) != content {
+			t.Errorf("%s: invalid content %q", name, data)
+		}
+		f.Close()
+	}
+
+	if warns := l.Warn.String(); !strings.Contains(warns, "mkdir error") {
+		t.Errorf("invalid warn: %s", warns)
+	}
+}
+
+func TestSegments(t *testing.T) {
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"/data/app-10.log", true},
+		{"/data/app-10.log.gz", true},
+		{"/data/app-10.log.3", true},
+		{"/data/app-10.log.3.gz", true},
+		{"/data/app-10.log.bak", false},
+		{"/data/app-10.log.gz.tmp", false},
+		{"/data/app-10.log.x3", false},
+		{"/data/app-10.log.", false},
+		{"/data/app.log", false},
+	}
+	pattern := segmentGlob("/data/app-%H.log")
+	for _, test := range tests {
+		if ok := isSegment(pattern, test.name); ok != test.ok {
+			t.Errorf("%s: invalid %v", test.name, ok)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package out_file
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	gzipExt = ".gz"
+)
+
+// strftime formats t by %Y, %y, %m, %d, %j, %H, %M, %S and %%.
+// Other directives are left as they are.
+func strftime(layout string, t time.Time) string {
+	if !strings.Contains(layout, "%") {
+		return layout
+	}
+	var b strings.Builder
+	for i := 0; i < len(layout); i++ {
+		c := layout[i]
+		if c != '%' || i+1 >= len(layout) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch layout[i] {
+		case 'Y':
+			fmt.Fprintf(&b, "%04d", t.Year())
+		case 'y':
+			fmt.Fprintf(&b, "%02d", t.Year()%100)
+		case 'm':
+			fmt.Fprintf(&b, "%02d", int(t.Month()))
+		case 'd':
+			fmt.Fprintf(&b, "%02d", t.Day())
+		case 'j':
+			fmt.Fprintf(&b, "%03d", t.YearDay())
+		case 'H':
+			fmt.Fprintf(&b, "%02d", t.Hour())
+		case 'M':
+			fmt.Fprintf(&b, "%02d", t.Minute())
+		case 'S':
+			fmt.Fprintf(&b, "%02d", t.Second())
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(layout[i])
+		}
+	}
+	return b.String()
+}
+
+// segmentGlob returns the pattern matching the files of the layout,
+// replacing the directives by "*".
+func segmentGlob(layout string) string {
+	var b strings.Builder
+	for i := 0; i < len(layout); i++ {
+		c := layout[i]
+		if c == '%' && i+1 < len(layout) && strings.IndexByte("YymdjHMS", layout[i+1]) >= 0 {
+			b.WriteByte('*')
+			i++
+		} else if c == '%' && i+1 < len(layout) && layout[i+1] == '%' {
+			b.WriteByte('%')
+			i++
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// nextSegment returns the name "path.N" not used yet, compressed or not.
+func nextSegment(path string) string {
+	for n := 1; ; n++ {
+		name := fmt.Sprintf("%s.%d", path, n)
+		if !exists(name) && !exists(name+gzipExt) {
+			return name
+		}
+	}
+}
+
+func exists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+// gzipFile compresses the file to "name.gz" and removes it.
+func gzipFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := name + gzipExt + ".tmp"
+	dst, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if err == nil {
+		err = gz.Close()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, name+gzipExt)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Remove(name)
+}
+
+type segment struct {
+	name    string
+	modTime time.Time
+}
+
+// segments returns the closed segments of the layout except current,
+// from the newest.
+func segments(layout, current string) ([]segment, error) {
+	pattern := segmentGlob(layout)
+	names, err := filepath.Glob(pattern + "*")
+	if err != nil {
+		return nil, err
+	}
+
+	var segs []segment
+	for _, name := range names {
+		if name == current || !isSegment(pattern, name) {
+			continue
+		}
+		info, err := os.Stat(name)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		segs = append(segs, segment{name: name, modTime: info.ModTime()})
+	}
+	sort.Slice(segs, func(i, j int) bool {
+		return segs[i].modTime.After(segs[j].modTime)
+	})
+	return segs, nil
+}
+
+// isSegment reports whether the name matches the pattern, or is
+// "name.N" rotated by the size, and compressed or not.
+// Other files such as "name.bak" are not segments.
+func isSegment(pattern, name string) bool {
+	name = strings.TrimSuffix(name, gzipExt)
+	if ok, _ := filepath.Match(pattern, name); ok {
+		return true
+	}
+	i := strings.LastIndexByte(name, '.')
+	if i < 0 || !isDigits(name[i+1:]) {
+		return false
+	}
+	ok, _ := filepath.Match(pattern, name[:i])
+	return ok
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
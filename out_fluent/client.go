@@ -0,0 +1,453 @@
+package out_fluent
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/vmihailenco/msgpack"
+
+	"github.com/najeira/gigo"
+	"github.com/najeira/gigo/forward"
+)
+
+var (
+	errClosed            = errors.New("out_fluent: closed")
+	errNoServer          = errors.New("out_fluent: no server")
+	errAckMismatch       = errors.New("out_fluent: ack mismatch")
+	errSharedKeyMismatch = errors.New("out_fluent: shared key mismatch")
+)
+
+const (
+	defaultBatchSize     = 1000
+	defaultChunkBytes    = 1024 * 1024
+	defaultFlushInterval = time.Second
+	defaultQueueSize     = 8
+	defaultTimeout       = time.Second * 3
+	defaultAckTimeout    = time.Second * 30
+	defaultRetryWait     = time.Second
+	defaultMaxRetryWait  = time.Minute
+	defaultMaxRetries    = 5
+	defaultRecoverWait   = time.Second * 10
+)
+
+// Server is the address of a fluentd.
+type Server struct {
+	// Network is "tcp" or "unix". Default is "tcp".
+	Network string
+	Addr    string
+}
+
+type ClientConfig struct {
+	// Servers receive the chunks in turn. A server failed to receive
+	// is skipped until RecoverWait passes, unless all are failed.
+	Servers []Server
+
+	// SharedKey authenticates by the handshake if not empty.
+	SharedKey string
+
+	// Hostname is sent in the handshake. Default is os.Hostname.
+	Hostname string
+
+	// BatchSize and ChunkBytes are the max entries and bytes of a chunk.
+	// Defaults are 1000 entries and 1MB.
+	BatchSize  int
+	ChunkBytes int
+
+	// FlushInterval sends the chunks not full. Default is 1s.
+	FlushInterval time.Duration
+
+	// QueueSize is the number of the chunks waiting to be sent.
+	// PostWithTime blocks while the queue is full. Default is 8.
+	QueueSize int
+
+	// Compress compresses the chunks by gzip.
+	Compress bool
+
+	// RequireAck waits for the ack of each chunk until AckTimeout,
+	// and sends it again on timeout. Default AckTimeout is 30s.
+	RequireAck bool
+	AckTimeout time.Duration
+
+	// Timeout is the timeout of dialing and writing. Default is 3s.
+	Timeout time.Duration
+
+	// RetryWait is the first wait to retry a chunk, doubled for each retry
+	// up to 1m. A chunk is dropped after MaxRetries. Defaults are 1s and 5.
+	RetryWait  time.Duration
+	MaxRetries int
+
+	// RecoverWait is the time to skip a server failed. Default is 10s.
+	RecoverWait time.Duration
+}
+
+// Client sends the entries to fluentd by the Forward protocol.
+// The entries are batched into a PackedForward chunk by tag, and
+// sent in order by a goroutine.
+type Client struct {
+	config ClientConfig
+	logger gigo.Logger
+
+	servers []*server
+	next    int
+
+	mu      sync.Mutex
+	pending map[string]*chunk
+	closed  bool
+	entry   bytes.Buffer
+	enc     *msgpack.Encoder
+
+	// order is held from taking chunks under mu until queued,
+	// so the chunks are queued in the order taken.
+	order sync.Mutex
+
+	queue   chan *chunk
+	closing chan struct{}
+	done    sync.WaitGroup
+}
+
+type chunk struct {
+	tag     string
+	buf     bytes.Buffer
+	entries int
+}
+
+func NewClient(config ClientConfig, logger gigo.Logger) (*Client, error) {
+	if len(config.Servers) <= 0 {
+		return nil, errNoServer
+	}
+	setDefault(&config)
+
+	c := &Client{
+		config:  config,
+		logger:  gigo.EnsureLogger(logger),
+		pending: make(map[string]*chunk),
+		queue:   make(chan *chunk, config.QueueSize),
+		closing: make(chan struct{}),
+	}
+	c.enc = msgpack.NewEncoder(&c.entry)
+	for _, s := range config.Servers {
+		network := s.Network
+		if network == "" {
+			network = "tcp"
+		}
+		c.servers = append(c.servers, &server{client: c, network: network, address: s.Addr})
+	}
+
+	c.done.Add(2)
+	go c.send()
+	go c.flush()
+	return c, nil
+}
+
+func setDefault(config *ClientConfig) {
+	if config.Hostname == "" {
+		config.Hostname = hostname()
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultBatchSize
+	}
+	if config.ChunkBytes <= 0 {
+		config.ChunkBytes = defaultChunkBytes
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaultFlushInterval
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaultQueueSize
+	}
+	if config.AckTimeout <= 0 {
+		config.AckTimeout = defaultAckTimeout
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	if config.RetryWait <= 0 {
+		config.RetryWait = defaultRetryWait
+	}
+	if config.MaxRetries <= 0 {
+		config.MaxRetries = defaultMaxRetries
+	}
+	if config.RecoverWait <= 0 {
+		config.RecoverWait = defaultRecoverWait
+	}
+}
+
+// PostWithTime adds an entry to the chunk of the tag.
+func (c *Client) PostWithTime(tag string, t time.Time, msg interface{}) error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return errClosed
+	}
+
+	// encode apart not to leave a part of the entry in the chunk on error
+	c.entry.Reset()
+	if err := forward.EncodeEntry(c.enc, t, msg); err != nil {
+		c.mu.Unlock()
+		return err
+	}
+	ch := c.pending[tag]
+	if ch == nil {
+		ch = &chunk{tag: tag}
+		c.pending[tag] = ch
+	}
+	ch.buf.Write(c.entry.Bytes())
+	ch.entries++
+	if ch.entries < c.config.BatchSize && ch.buf.Len() < c.config.ChunkBytes {
+		c.mu.Unlock()
+		return nil
+	}
+	delete(c.pending, tag)
+	c.enqueue([]*chunk{ch})
+	return nil
+}
+
+// takePending removes and returns all the pending chunks.
+// It must be called with mu.
+func (c *Client) takePending() []*chunk {
+	chunks := make([]*chunk, 0, len(c.pending))
+	for tag, ch := range c.pending {
+		delete(c.pending, tag)
+		chunks = append(chunks, ch)
+	}
+	return chunks
+}
+
+// enqueue queues the chunks, blocking while the queue is full.
+// It must be called with mu, and unlocks mu before blocking.
+func (c *Client) enqueue(chunks []*chunk) {
+	c.order.Lock()
+	defer c.order.Unlock()
+	c.mu.Unlock()
+	for _, ch := range chunks {
+		c.queue <- ch
+	}
+}
+
+func (c *Client) flush() {
+	defer c.done.Done()
+	ticker := time.NewTicker(c.config.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.mu.Lock()
+			if c.closed || len(c.pending) <= 0 {
+				c.mu.Unlock()
+				continue
+			}
+			c.enqueue(c.takePending())
+		case <-c.closing:
+			return
+		}
+	}
+}
+
+func (c *Client) send() {
+	defer c.done.Done()
+	for ch := range c.queue {
+		c.sendChunk(ch)
+	}
+	for _, s := range c.servers {
+		s.close()
+	}
+}
+
+// sendChunk sends the chunk to the servers in turn until succeeded
+// or MaxRetries. The chunk id is kept for the retries, which do not
+// wait after Close.
+func (c *Client) sendChunk(ch *chunk) {
+	option := forward.Option{Size: ch.entries}
+	if c.config.Compress {
+		option.Compressed = forward.CompressedGzip
+	}
+	if c.config.RequireAck {
+		option.Chunk = newChunkID()
+	}
+	var buf bytes.Buffer
+	if err := forward.EncodePacked(msgpack.NewEncoder(&buf), ch.tag, ch.buf.Bytes(), option); err != nil {
+		c.logger.Errorf("out_fluent: encode error %s", err)
+		return
+	}
+
+	wait := c.config.RetryWait
+	for retry := 0; ; retry++ {
+		s := c.pickServer()
+		err := s.write(buf.Bytes(), option.Chunk)
+		if err == nil {
+			return
+		}
+		c.logger.Warnf("out_fluent: send error %s to %s", err, s.address)
+		s.fail()
+
+		if retry >= c.config.MaxRetries {
+			c.logger.Errorf("out_fluent: drop %d entries of %s", ch.entries, ch.tag)
+			return
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-c.closing:
+			timer.Stop()
+		}
+		if wait *= 2; wait > defaultMaxRetryWait {
+			wait = defaultMaxRetryWait
+		}
+	}
+}
+
+// pickServer returns the next server not failed recently,
+// or the one failed earliest if all are failed.
+func (c *Client) pickServer() *server {
+	now := time.Now()
+	var earliest *server
+	for i := 0; i < len(c.servers); i++ {
+		s := c.servers[(c.next+i)%len(c.servers)]
+		if !now.Before(s.downUntil) {
+			c.next = (c.next + i + 1) % len(c.servers)
+			return s
+		}
+		if earliest == nil || s.downUntil.Before(earliest.downUntil) {
+			earliest = s
+		}
+	}
+	return earliest
+}
+
+// Close sends the pending entries and waits for the chunks to be sent.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return errClosed
+	}
+	c.closed = true
+	close(c.closing)
+
+	// the chunks taken before are queued ahead by order
+	c.enqueue(c.takePending())
+	close(c.queue)
+	c.done.Wait()
+	return nil
+}
+
+func newChunkID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// server is a connection to a fluentd, used only by the send goroutine.
+type server struct {
+	client    *Client
+	network   string
+	address   string
+	conn      net.Conn
+	dec       *msgpack.Decoder
+	downUntil time.Time
+}
+
+// write writes the message, and waits for the ack if chunkID is not empty.
+func (s *server) write(data []byte, chunkID string) error {
+	if s.conn == nil {
+		if err := s.connect(); err != nil {
+			return err
+		}
+	}
+	config := &s.client.config
+
+	s.conn.SetWriteDeadline(time.Now().Add(config.Timeout))
+	if _, err := s.conn.Write(data); err != nil {
+		return err
+	}
+	if chunkID == "" {
+		return nil
+	}
+
+	s.conn.SetReadDeadline(time.Now().Add(config.AckTimeout))
+	var ack forward.Ack
+	if err := s.dec.Decode(&ack); err != nil {
+		return err
+	}
+	if ack.Ack != chunkID {
+		return errAckMismatch
+	}
+	return nil
+}
+
+func (s *server) connect() error {
+	config := &s.client.config
+	conn, err := net.DialTimeout(s.network, s.address, config.Timeout)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	s.dec = msgpack.NewDecoder(bufio.NewReader(conn))
+	if config.SharedKey != "" {
+		conn.SetDeadline(time.Now().Add(config.Timeout))
+		if err := s.handshake(); err != nil {
+			s.close()
+			return err
+		}
+		conn.SetDeadline(time.Time{})
+	}
+	s.client.logger.Debugf("out_fluent: connect %s", s.address)
+	return nil
+}
+
+// handshake authenticates by the shared key, and checks the server
+// knows the key too.
+func (s *server) handshake() error {
+	config := &s.client.config
+	key := []byte(config.SharedKey)
+	helo, err := forward.DecodeHelo(s.dec)
+	if err != nil {
+		return err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	ping := forward.Ping{
+		Hostname: config.Hostname,
+		Salt:     salt,
+		Digest:   forward.Digest(salt, []byte(config.Hostname), helo.Nonce, key),
+	}
+	if err := ping.Encode(msgpack.NewEncoder(s.conn)); err != nil {
+		return err
+	}
+
+	pong, err := forward.DecodePong(s.dec)
+	if err != nil {
+		return err
+	}
+	if !pong.OK {
+		return fmt.Errorf("out_fluent: authentication failed: %s", pong.Reason)
+	}
+	if pong.Digest != forward.Digest(salt, []byte(pong.Hostname), helo.Nonce, key) {
+		return errSharedKeyMismatch
+	}
+	return nil
+}
+
+// fail closes the connection and skips the server until RecoverWait.
+func (s *server) fail() {
+	s.close()
+	s.downUntil = time.Now().Add(s.client.config.RecoverWait)
+}
+
+func (s *server) close() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+		s.dec = nil
+	}
+}
@@ -2,26 +2,38 @@ package out_fluent
 
 import (
 	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
 
-	"github.com/fluent/fluent-logger-golang/fluent"
 	"github.com/najeira/gigo"
 )
 
+func init() {
+	gigo.RegisterOutput("fluent", newOutput)
+}
+
 type Config struct {
-	fluent.Config
-	Tag       string
+	ClientConfig
+
+	// Tag is the tag sent, such as "app.${record.service}.${tag_parts[1]}".
+	// ${tag} is the tag of the record. Default is "${tag}".
+	Tag string
+
 	FieldName string
 	Logger    gigo.Logger
 }
 
 type Fluent interface {
-	Post(string, interface{}) error
+	PostWithTime(string, time.Time, interface{}) error
 	Close() error
 }
 
 type Output struct {
-	config    fluent.Config
-	tag       string
+	config    ClientConfig
+	tag       *tagTemplate
+	tagErr    error
 	fieldName string
 	logger    gigo.Logger
 	output    Fluent
@@ -30,12 +42,97 @@ type Output struct {
 var _ gigo.Output = (*Output)(nil)
 
 func New(config Config) *Output {
-	return &Output{
-		config:    config.Config,
-		tag:       config.Tag,
+	p := &Output{
+		config:    config.ClientConfig,
 		fieldName: config.FieldName,
 		logger:    config.Logger,
 	}
+	tag := config.Tag
+	if tag == "" {
+		tag = "${tag}"
+	}
+	p.tag, p.tagErr = newTagTemplate(tag)
+	return p
+}
+
+func newOutput(config gigo.PluginConfig) (gigo.Output, error) {
+	servers, err := serversFrom(config)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := newTagTemplate(config.String("tag", "")); err != nil {
+		return nil, err
+	}
+	flushInterval, err := config.Duration("flush_interval", defaultFlushInterval)
+	if err != nil {
+		return nil, err
+	}
+	ackTimeout, err := config.Duration("ack_response_timeout", defaultAckTimeout)
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := config.Duration("timeout", defaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+	retryWait, err := config.Duration("retry_wait", defaultRetryWait)
+	if err != nil {
+		return nil, err
+	}
+	recoverWait, err := config.Duration("recover_wait", defaultRecoverWait)
+	if err != nil {
+		return nil, err
+	}
+	return New(Config{
+		ClientConfig: ClientConfig{
+			Servers:       servers,
+			SharedKey:     config.String("shared_key", ""),
+			Hostname:      config.String("self_hostname", ""),
+			BatchSize:     int(config.Int("batch_size", defaultBatchSize)),
+			ChunkBytes:    int(config.Int("chunk_bytes", defaultChunkBytes)),
+			FlushInterval: flushInterval,
+			QueueSize:     int(config.Int("queue_size", defaultQueueSize)),
+			Compress:      config.String("compress", "") == "gzip",
+			RequireAck:    config.Bool("require_ack_response", false),
+			AckTimeout:    ackTimeout,
+			Timeout:       timeout,
+			RetryWait:     retryWait,
+			MaxRetries:    int(config.Int("max_retries", defaultMaxRetries)),
+			RecoverWait:   recoverWait,
+		},
+		Logger:    config.Logger(),
+		Tag:       config.String("tag", ""),
+		FieldName: config.String("field_name", "message"),
+	}), nil
+}
+
+// serversFrom returns the servers of "servers" such as "host:port",
+// or the one of "host" and "port".
+func serversFrom(config gigo.PluginConfig) ([]Server, error) {
+	network := config.String("network", "tcp")
+	addrs := config.Strings("servers")
+	if len(addrs) <= 0 {
+		host := config.String("host", "127.0.0.1")
+		port := config.Int("port", 24224)
+		if network == "unix" {
+			addrs = []string{host}
+		} else {
+			addrs = []string{net.JoinHostPort(host, strconv.FormatInt(port, 10))}
+		}
+	}
+	servers := make([]Server, 0, len(addrs))
+	for _, addr := range addrs {
+		if addr == "" {
+			return nil, fmt.Errorf("out_fluent: empty server")
+		}
+		servers = append(servers, Server{Network: network, Addr: addr})
+	}
+	return servers, nil
+}
+
+func hostname() string {
+	name, _ := os.Hostname()
+	return name
 }
 
 func (p *Output) Start() error {
@@ -43,7 +140,10 @@ func (p *Output) Start() error {
 	if p.output != nil {
 		return fmt.Errorf("already started")
 	}
-	output, err := fluent.New(p.config)
+	if p.tagErr != nil {
+		return p.tagErr
+	}
+	output, err := NewClient(p.config, p.logger)
 	if err != nil {
 		return err
 	}
@@ -56,14 +156,33 @@ func (p *Output) Stop() error {
 	if p.output == nil {
 		return fmt.Errorf("not started")
 	}
-	p.output.Close()
+	err := p.output.Close()
+	p.output = nil
+	return err
+}
+
+func (p *Output) Health() error {
+	if p.output == nil {
+		return gigo.ErrNotStarted
+	}
 	return nil
 }
 
-func (p *Output) Emit(msg interface{}) error {
+// Emit posts the record fields with the record time and the tag made
+// from the record. Raw bytes are posted as FieldName unless the record
+// has the field, such as "message" parsed from the raw line.
+func (p *Output) Emit(record *gigo.Record) error {
 	if p.output == nil {
 		return fmt.Errorf("not started")
 	}
-	v := map[string]interface{}{p.fieldName: msg}
-	return p.output.Post(p.tag, v)
+	v := make(map[string]interface{}, len(record.Fields)+1)
+	for key, value := range record.Fields {
+		v[key] = value
+	}
+	if record.Raw != nil && p.fieldName != "" {
+		if _, ok := v[p.fieldName]; !ok {
+			v[p.fieldName] = string(record.Raw)
+		}
+	}
+	return p.output.PostWithTime(p.tag.execute(record), record.Time, v)
 }
@@ -2,10 +2,16 @@ package out_fluent
 
 import (
 	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
-	"github.com/fluent/fluent-logger-golang/fluent"
-	//"github.com/najeira/gigo/testutil"
+	"github.com/najeira/gigo"
+	"github.com/najeira/gigo/in_forward"
+	"github.com/najeira/gigo/testutil"
 )
 
 type value struct {
@@ -17,7 +23,7 @@ type testFluent struct {
 	messages []value
 }
 
-func (f *testFluent) Post(tag string, msg interface{}) error {
+func (f *testFluent) PostWithTime(tag string, t time.Time, msg interface{}) error {
 	f.messages = append(f.messages, value{tag: tag, msg: msg})
 	return nil
 }
@@ -47,7 +53,6 @@ func checkValue(v value, tag, field, str string) error {
 func TestEmit(t *testing.T) {
 	f := testFluent{}
 	o := New(Config{
-		Config:    fluent.Config{},
 		Tag:       "tag",
 		FieldName: "message",
 	})
@@ -55,17 +60,17 @@ func TestEmit(t *testing.T) {
 
 	var err error
 
-	err = o.Emit("hoge")
+	err = o.Emit(gigo.NewRecord("in", []byte("hoge")))
 	if err != nil {
 		t.Error(err)
 	}
 
-	err = o.Emit("fuga")
+	err = o.Emit(gigo.NewRecord("in", []byte("fuga")))
 	if err != nil {
 		t.Error(err)
 	}
 
-	err = o.Emit("piyo")
+	err = o.Emit(gigo.NewRecord("in", []byte("piyo")))
 	if err != nil {
 		t.Error(err)
 	}
@@ -85,4 +90,164 @@ func TestEmit(t *testing.T) {
 	if err = checkValue(f.messages[2], "tag", "message", "piyo"); err != nil {
 		t.Error(err)
 	}
+
+	// the parsed field is kept
+	record := gigo.NewRecord("in", []byte(`{"message":"parsed"}`))
+	record.Set("message", "parsed")
+	if err = o.Emit(record); err != nil {
+		t.Error(err)
+	}
+	if err = checkValue(f.messages[3], "tag", "message", "parsed"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClient(t *testing.T) {
+	var mu sync.Mutex
+	var rets []string
+	emitter := gigo.EmitterFunc(func(record *gigo.Record) error {
+		mu.Lock()
+		defer mu.Unlock()
+		rets = append(rets, record.Tag+":"+record.GetString("msg"))
+		return nil
+	})
+	r := in_forward.New(in_forward.Config{Addr: "127.0.0.1:0", SharedKey: "secret"})
+	if err := r.Start(emitter); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	// no server listens on the address
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	down := ln.Addr().String()
+	ln.Close()
+
+	l := testutil.Logger{}
+	c, err := NewClient(ClientConfig{
+		Servers:    []Server{{Addr: down}, {Addr: r.Addr().String()}},
+		SharedKey:  "secret",
+		BatchSize:  2,
+		Compress:   true,
+		RequireAck: true,
+		RetryWait:  time.Millisecond * 10,
+	}, &l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range []string{"a", "b", "c"} {
+		err := c.PostWithTime("app", time.Now(), map[string]interface{}{"msg": msg})
+		if err != nil {
+			t.Error(err)
+		}
+		// an entry failed to encode is not in the chunk
+		err = c.PostWithTime("app", time.Now(), map[string]interface{}{"msg": msg, "ch": make(chan int)})
+		if err == nil {
+			t.Error("no error for invalid entry")
+		}
+	}
+	if err := c.Close(); err != nil {
+		t.Error(err)
+	}
+
+	mu.Lock()
+	sort.Strings(rets)
+	if ret := strings.Join(rets, ","); ret != "app:a,app:b,app:c" {
+		t.Errorf("invalid emit %s", ret)
+	}
+	mu.Unlock()
+	if errs := l.Error.String(); errs != "" {
+		t.Error(errs)
+	}
+}
+
+func TestClientOrder(t *testing.T) {
+	var mu sync.Mutex
+	var rets []string
+	emitter := gigo.EmitterFunc(func(record *gigo.Record) error {
+		mu.Lock()
+		defer mu.Unlock()
+		rets = append(rets, record.GetString("msg"))
+		return nil
+	})
+	r := in_forward.New(in_forward.Config{Addr: "127.0.0.1:0"})
+	if err := r.Start(emitter); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	// the chunks full and flushed by the interval are queued in order
+	l := testutil.Logger{}
+	c, err := NewClient(ClientConfig{
+		Servers:       []Server{{Addr: r.Addr().String()}},
+		BatchSize:     3,
+		FlushInterval: time.Millisecond,
+		QueueSize:     1,
+		RequireAck:    true,
+	}, &l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected []string
+	for i := 0; i < 300; i++ {
+		msg := fmt.Sprintf("%03d", i)
+		expected = append(expected, msg)
+		if err := c.PostWithTime("app", time.Now(), map[string]interface{}{"msg": msg}); err != nil {
+			t.Error(err)
+		}
+	}
+	if err := c.Close(); err != nil {
+		t.Error(err)
+	}
+
+	mu.Lock()
+	if ret := strings.Join(rets, ","); ret != strings.Join(expected, ",") {
+		t.Errorf("invalid emit %s", ret)
+	}
+	mu.Unlock()
+	if errs := l.Error.String(); errs != "" {
+		t.Error(errs)
+	}
+}
+
+func TestTagTemplate(t *testing.T) {
+	record := gigo.NewRecord("in.web.access", nil)
+	record.Set("service", "api")
+	record.Set("code", 200)
+	record.Set("kubernetes", map[string]interface{}{"namespace": "prod"})
+
+	tests := []struct {
+		tag    string
+		output string
+	}{
+		{"", "in.web.access"},
+		{"fixed", "fixed"},
+		{"app.${record.service}.${tag_parts[1]}", "app.api.web"},
+		{"${tag}.${record.code}", "in.web.access.200"},
+		{"${record.kubernetes.namespace}.${tag_parts[-1]}", "prod.access"},
+		{"x.${record.none}.${tag_parts[5]}", "x.."},
+	}
+	for _, test := range tests {
+		f := testFluent{}
+		o := New(Config{Tag: test.tag})
+		if o.tagErr != nil {
+			t.Errorf("%s: %s", test.tag, o.tagErr)
+			continue
+		}
+		o.output = &f
+		if err := o.Emit(record); err != nil {
+			t.Error(err)
+		}
+		if len(f.messages) != 1 || f.messages[0].tag != test.output {
+			t.Errorf("%s: invalid tag %v", test.tag, f.messages)
+		}
+	}
+
+	for _, tag := range []string{"${tag", "${unknown}", "${tag_parts[x]}", "${record.}"} {
+		if _, err := newTagTemplate(tag); err == nil {
+			t.Errorf("%s: no error", tag)
+		}
+	}
 }
@@ -0,0 +1,134 @@
+package out_fluent
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/najeira/gigo"
+)
+
+// tagTemplate makes the tag of a record by the placeholders:
+// ${tag} is the tag of the record, ${tag_parts[N]} is the Nth part of it
+// split by "." (from the end if negative), and ${record.key} is the field,
+// which may be nested such as ${record.kubernetes.namespace}.
+// Missing parts and fields are empty.
+type tagTemplate struct {
+	parts []tagPart
+}
+
+type tagPart struct {
+	literal string
+	value   func(record *gigo.Record) string
+}
+
+func newTagTemplate(s string) (*tagTemplate, error) {
+	t := &tagTemplate{}
+	for {
+		start := strings.Index(s, "${")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(s[start:], "}")
+		if end < 0 {
+			return nil, fmt.Errorf("out_fluent: unclosed placeholder in tag %s", s)
+		}
+		end += start
+		value, err := placeholder(s[start+2 : end])
+		if err != nil {
+			return nil, err
+		}
+		if start > 0 {
+			t.parts = append(t.parts, tagPart{literal: s[:start]})
+		}
+		t.parts = append(t.parts, tagPart{value: value})
+		s = s[end+1:]
+	}
+	if s != "" {
+		t.parts = append(t.parts, tagPart{literal: s})
+	}
+	return t, nil
+}
+
+func placeholder(name string) (func(record *gigo.Record) string, error) {
+	switch {
+	case name == "tag":
+		return func(record *gigo.Record) string {
+			return record.Tag
+		}, nil
+	case strings.HasPrefix(name, "tag_parts[") && strings.HasSuffix(name, "]"):
+		i, err := strconv.Atoi(name[len("tag_parts[") : len(name)-1])
+		if err != nil {
+			return nil, fmt.Errorf("out_fluent: invalid placeholder ${%s}", name)
+		}
+		return func(record *gigo.Record) string {
+			return tagPartAt(record.Tag, i)
+		}, nil
+	case strings.HasPrefix(name, "record.") && len(name) > len("record."):
+		keys := strings.Split(name[len("record."):], ".")
+		return func(record *gigo.Record) string {
+			return fieldString(record.Fields, keys)
+		}, nil
+	}
+	return nil, fmt.Errorf("out_fluent: unknown placeholder ${%s}", name)
+}
+
+func tagPartAt(tag string, i int) string {
+	parts := strings.Split(tag, ".")
+	if i < 0 {
+		i += len(parts)
+	}
+	if i < 0 || i >= len(parts) {
+		return ""
+	}
+	return parts[i]
+}
+
+func fieldString(fields map[string]interface{}, keys []string) string {
+	var v interface{} = fields
+	for _, key := range keys {
+		switch m := v.(type) {
+		case map[string]interface{}:
+			v = m[key]
+		case map[interface{}]interface{}:
+			v = m[key]
+		default:
+			return ""
+		}
+	}
+	switch s := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return s
+	case []byte:
+		return string(s)
+	}
+	return fmt.Sprint(v)
+}
+
+// constant returns the tag and true if it has no placeholder.
+func (t *tagTemplate) constant() (string, bool) {
+	switch len(t.parts) {
+	case 0:
+		return "", true
+	case 1:
+		return t.parts[0].literal, t.parts[0].value == nil
+	}
+	return "", false
+}
+
+func (t *tagTemplate) execute(record *gigo.Record) string {
+	if s, ok := t.constant(); ok {
+		return s
+	}
+	var b strings.Builder
+	for _, part := range t.parts {
+		if part.value != nil {
+			b.WriteString(part.value(record))
+		} else {
+			b.WriteString(part.literal)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,322 @@
+package out_s3
+
+import (
+	"bytes"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+
+	"github.com/najeira/gigo"
+	"github.com/najeira/gigo/formatter"
+)
+
+const (
+	DefaultTimeFormat    = "2006-01-02-15-04-05"
+	DefaultBufferSize    = 10 * 1000 * 1000
+	DefaultFlushInterval = 13 * 60 // seconds
+
+	flushRetries      = 5
+	flushRetryWait    = time.Second
+	flushMaxRetryWait = time.Minute
+)
+
+var (
+	_ gigo.Output = (*BufferedWriter)(nil)
+)
+
+type BufferedConfig struct {
+	Key               string
+	Secret            string
+	Region            string
+	Bucket            string
+	Path              string
+	Hostname          bool
+	PublicRead        bool
+	ReducedRedundancy bool
+	TimeFormat        string
+	BufferSize        int
+	FlushInterval     int64
+	Formatter         formatter.Formatter
+}
+
+// BufferedWriter writes data to S3.
+// Data is buffered and put as a new object by the size or the interval.
+type BufferedWriter struct {
+	gigo.Mixin
+
+	// config
+	config   BufferedConfig
+	cred     *credentials.Credentials
+	hostname string
+
+	// writer
+	mu       sync.Mutex
+	writer   *Writer
+	sequence int64
+	closed   bool
+
+	// ready flush to S3, sent without mu by the goroutines
+	// counted by sending
+	ready   chan *Writer
+	sending sync.WaitGroup
+
+	// closing stops waiting to retry flushes
+	closing chan struct{}
+
+	// wait for the flush loop to end
+	done chan struct{}
+}
+
+// Creates a new BufferedWriter.
+func NewBufferedWriter(config BufferedConfig) (*BufferedWriter, error) {
+	var cred *credentials.Credentials
+	if config.Key != "" && config.Secret != "" {
+		cred = credentials.NewStaticCredentials(config.Key, config.Secret, "")
+	}
+
+	var hostname string
+	if config.Hostname {
+		hostname_, err := os.Hostname()
+		if err != nil {
+			return nil, err
+		}
+		hostname = hostname_
+	}
+
+	if config.Formatter == nil {
+		config.Formatter = formatter.NewRaw(formatter.RawConfig{})
+	}
+
+	w := &BufferedWriter{
+		config:   config,
+		cred:     cred,
+		hostname: hostname,
+		ready:    make(chan *Writer, 1),
+		closing:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	w.Name = "out_buf"
+	return w, nil
+}
+
+func (w *BufferedWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+
+	if w.closed {
+		w.mu.Unlock()
+		w.Info(ErrClosed)
+		return 0, ErrClosed
+	}
+
+	if w.writer == nil {
+		// init first writer
+		w.rotateImpl(true)
+		go w.flush()
+	}
+
+	n, err := w.writer.Write(data)
+	if err != nil {
+		w.mu.Unlock()
+		w.Error(err)
+		return n, err
+	}
+
+	if w.writer.Len() <= w.config.BufferSize {
+		w.mu.Unlock()
+		return n, err
+	}
+
+	// current writer is full
+	w.Infof("rotate by buffer size")
+	full := w.rotateImpl(true)
+	w.sending.Add(1)
+	w.mu.Unlock()
+
+	// blocks while the previous one is flushing
+	w.ready <- full
+	w.sending.Done()
+	w.Debug("enqueue")
+	return n, err
+}
+
+// rotate replaces the writer, and returns the previous one to flush,
+// or nil if empty. No writer is made next once closed.
+func (w *BufferedWriter) rotate(next bool) *Writer {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.rotateImpl(next && !w.closed)
+}
+
+func (w *BufferedWriter) rotateImpl(next bool) *Writer {
+	var prev *Writer
+	if w.writer != nil {
+		if w.writer.Len() > 0 {
+			prev = w.writer
+		}
+		w.writer = nil
+	}
+
+	if !next {
+		return prev
+	}
+
+	now := time.Now().Unix()
+	if w.sequence < now {
+		w.sequence = now
+	} else {
+		// same seconds, force forward
+		w.sequence += 1
+	}
+	seqTime := time.Unix(w.sequence, 0)
+	timeKey := seqTime.Format(w.config.TimeFormat)
+	fileKey := getFileKey(w.config.Path, timeKey, w.hostname)
+
+	output := New(Config{
+		Credentials:       w.cred,
+		Region:            w.config.Region,
+		Bucket:            w.config.Bucket,
+		Key:               fileKey,
+		PublicRead:        w.config.PublicRead,
+		ReducedRedundancy: w.config.ReducedRedundancy,
+	})
+	output.Mixin = w.Mixin
+	output.Name = pluginName
+	w.writer = output
+	w.Debugf("new writer %s", fileKey)
+	return prev
+}
+
+func (w *BufferedWriter) flush() {
+	defer close(w.done)
+
+	interval := time.Duration(w.config.FlushInterval) * time.Second
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	w.Debug("flush start")
+	for {
+		select {
+		case chunk, ok := <-w.ready:
+			if !ok {
+				// chan closed
+				w.Debug("flush end")
+				return
+			}
+
+			w.flushChunk(chunk)
+
+		case <-ticker.C:
+			// flush here, as this loop is the only receiver of ready
+			if chunk := w.rotate(true); chunk != nil {
+				w.flushChunk(chunk)
+			}
+		}
+	}
+}
+
+// flushChunk puts the chunk to S3, retrying with backoff.
+// The chunk is dropped after flushRetries. Once closing, it retries
+// without waiting.
+func (w *BufferedWriter) flushChunk(chunk *Writer) {
+	wait := flushRetryWait
+	for retry := 0; ; retry++ {
+		size := chunk.Len()
+		err := chunk.Flush()
+		if err == nil {
+			return
+		}
+		if retry >= flushRetries {
+			w.Errorf("drop %d bytes by %s", size, err)
+			return
+		}
+		w.Infof("flush error %s, retry after %s", err, wait)
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-w.closing:
+			timer.Stop()
+		}
+		if wait *= 2; wait > flushMaxRetryWait {
+			wait = flushMaxRetryWait
+		}
+	}
+}
+
+// Close flushes the buffered data and waits for the flushes to end.
+// Writes after Close return ErrClosed.
+func (w *BufferedWriter) Close() {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+	w.closed = true
+	started := w.sequence != 0
+	chunk := w.rotateImpl(false)
+	w.mu.Unlock()
+
+	close(w.closing)
+	if chunk != nil {
+		w.ready <- chunk
+	}
+	w.sending.Wait()
+	close(w.ready)
+	if started {
+		// the flush loop starts on the first Write
+		<-w.done
+	}
+	w.Debug("closed")
+}
+
+// Start does nothing; the first Write starts the flush loop.
+func (w *BufferedWriter) Start() error {
+	return nil
+}
+
+func (w *BufferedWriter) Stop() error {
+	w.Close()
+	return nil
+}
+
+// Emit writes the record formatted by the formatter.
+func (w *BufferedWriter) Emit(record *gigo.Record) error {
+	data, err := w.config.Formatter.Format(record)
+	if err != nil {
+		w.Error(err)
+		return err
+	}
+	if len(data) <= 0 {
+		return nil
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (w *BufferedWriter) Health() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return ErrClosed
+	}
+	return nil
+}
+
+// %{path}%{time}_%{hostname}.log
+func getFileKey(path string, timeKey string, hostname string) string {
+	var buf bytes.Buffer
+	if path != "" {
+		buf.WriteString(path)
+	}
+
+	buf.WriteString(timeKey)
+
+	if hostname != "" {
+		buf.WriteString("_")
+		buf.WriteString(hostname)
+	}
+
+	buf.WriteString(".log")
+	return buf.String()
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/aws/aws-sdk-go/service/s3"
 
 	"github.com/najeira/gigo"
+	"github.com/najeira/gigo/formatter"
 )
 
 const (
@@ -20,8 +21,14 @@ const (
 
 var (
 	ErrClosed = errors.New("out_s3: writer closed")
+
+	_ gigo.Output = (*Writer)(nil)
 )
 
+func init() {
+	gigo.RegisterOutput("s3", newOutput)
+}
+
 type Config struct {
 	Credentials       *credentials.Credentials
 	Region            string
@@ -29,6 +36,7 @@ type Config struct {
 	Key               string
 	PublicRead        bool
 	ReducedRedundancy bool
+	Formatter         formatter.Formatter
 }
 
 type Writer struct {
@@ -38,6 +46,7 @@ type Writer struct {
 	key               string
 	publicRead        bool
 	reducedRedundancy bool
+	formatter         formatter.Formatter
 
 	svc  s3Service
 	buf  *bytes.Buffer
@@ -52,11 +61,15 @@ func New(config Config) *Writer {
 		key:               config.Key,
 		reducedRedundancy: config.ReducedRedundancy,
 		publicRead:        config.PublicRead,
+		formatter:         config.Formatter,
 		svc:               newS3(config),
 		buf:               buf,
 		gw:                gzip.NewWriter(buf),
 		size:              0,
 	}
+	if w.formatter == nil {
+		w.formatter = formatter.NewRaw(formatter.RawConfig{})
+	}
 	w.Name = pluginName
 	return w
 }
@@ -78,6 +91,62 @@ func (w *Writer) Write(data []byte) (int, error) {
 	return n, nil
 }
 
+func newOutput(config gigo.PluginConfig) (gigo.Output, error) {
+	if err := config.Require("region", "bucket"); err != nil {
+		return nil, err
+	}
+	f, err := formatter.New(config)
+	if err != nil {
+		return nil, err
+	}
+	w, err := NewBufferedWriter(BufferedConfig{
+		Key:               config.String("key", ""),
+		Secret:            config.String("secret", ""),
+		Region:            config.String("region", ""),
+		Bucket:            config.String("bucket", ""),
+		Path:              config.String("path", ""),
+		Hostname:          config.Bool("hostname", false),
+		PublicRead:        config.Bool("public_read", false),
+		ReducedRedundancy: config.Bool("reduced_redundancy", false),
+		TimeFormat:        config.String("time_format", DefaultTimeFormat),
+		BufferSize:        int(config.Int("buffer_size", DefaultBufferSize)),
+		FlushInterval:     config.Int("flush_interval", DefaultFlushInterval),
+		Formatter:         f,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Start does nothing; the Writer buffers from New until Flush.
+func (w *Writer) Start() error {
+	return w.Health()
+}
+
+// Stop uploads the buffered data to S3.
+func (w *Writer) Stop() error {
+	return w.Flush()
+}
+
+// Emit writes the record formatted by the formatter.
+func (w *Writer) Emit(record *gigo.Record) error {
+	data, err := w.formatter.Format(record)
+	if err != nil {
+		w.Error(err)
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (w *Writer) Health() error {
+	if w.gw == nil {
+		return ErrClosed
+	}
+	return nil
+}
+
 func (w *Writer) Flush() error {
 	if w.buf == nil {
 		// already flushed to S3
@@ -3,9 +3,11 @@ package out_s3
 import (
 	"bytes"
 	"compress/gzip"
+	"errors"
 	"io"
 	"io/ioutil"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/s3"
@@ -24,6 +26,12 @@ func (svc *testS3Service) PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutp
 	}, nil
 }
 
+type errS3Service struct{}
+
+func (svc errS3Service) PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	return nil, errors.New("test error")
+}
+
 func TestNewWriteFlush(t *testing.T) {
 	svc := &testS3Service{}
 
@@ -65,3 +73,48 @@ func TestNewWriteFlush(t *testing.T) {
 		t.Errorf("invalid body: %s", str)
 	}
 }
+
+func TestBufferedClose(t *testing.T) {
+	w, err := NewBufferedWriter(BufferedConfig{FlushInterval: DefaultFlushInterval})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	if _, err := io.WriteString(w, "closed\n"); err != ErrClosed {
+		t.Errorf("invalid error %v", err)
+	}
+	if err := w.Health(); err != ErrClosed {
+		t.Errorf("invalid health %v", err)
+	}
+	// closed twice
+	w.Close()
+}
+
+func TestBufferedFlushRetry(t *testing.T) {
+	w, err := NewBufferedWriter(BufferedConfig{FlushInterval: DefaultFlushInterval})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunk := New(Config{Region: "ap-northeast-1", Bucket: "test"})
+	chunk.svc = errS3Service{}
+	io.WriteString(chunk, "retry\n")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.flushChunk(chunk)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	// closing stops waiting to retry
+	start := time.Now()
+	close(w.closing)
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("flush not ended")
+	}
+	if d := time.Since(start); d >= flushRetryWait {
+		t.Errorf("invalid wait %s", d)
+	}
+}
@@ -0,0 +1,69 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"unicode/utf8"
+
+	"github.com/najeira/gigo"
+)
+
+func init() {
+	Register("csv", newCSVParser)
+}
+
+type CSVConfig struct {
+	Config
+
+	// Columns are the field names of the values.
+	Columns []string
+
+	// Delimiter is the field delimiter. Default is ','.
+	Delimiter rune
+}
+
+// NewCSV returns a parser for a line of CSV.
+// Values exceeding the columns are ignored.
+func NewCSV(config CSVConfig) (Parser, error) {
+	if len(config.Columns) <= 0 {
+		return nil, errors.New("parser: csv columns are not configured")
+	}
+	columns := config.Columns
+	delimiter := config.Delimiter
+	if delimiter == 0 {
+		delimiter = ','
+	}
+	parse := func(data []byte) (map[string]interface{}, error) {
+		r := csv.NewReader(bytes.NewReader(data))
+		r.Comma = delimiter
+		r.FieldsPerRecord = -1
+		r.LazyQuotes = true
+		values, err := r.Read()
+		if err != nil {
+			return nil, err
+		}
+		fields := make(map[string]interface{}, len(columns))
+		for i, value := range values {
+			if i >= len(columns) {
+				break
+			}
+			fields[columns[i]] = value
+		}
+		return fields, nil
+	}
+	return newParser(parse, config.Config), nil
+}
+
+func newCSVParser(config gigo.PluginConfig) (Parser, error) {
+	c, err := newConfig(config, "", "")
+	if err != nil {
+		return nil, err
+	}
+	delimiter, _ := utf8.DecodeRuneInString(config.String("delimiter", ","))
+	return NewCSV(CSVConfig{
+		Config:    c,
+		Columns:   config.Strings("columns"),
+		Delimiter: delimiter,
+	})
+}
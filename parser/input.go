@@ -0,0 +1,70 @@
+package parser
+
+import (
+	"github.com/najeira/gigo"
+)
+
+func init() {
+	gigo.RegisterFilter("parser", newFilter)
+}
+
+var (
+	_ gigo.Input    = (*input)(nil)
+	_ gigo.Reloader = (*input)(nil)
+	_ gigo.Filter   = (*filter)(nil)
+)
+
+// Input returns an input parsing the records emitted by in.
+// A record failing to parse is emitted as it is, and the error
+// is returned to in.
+func Input(in gigo.Input, p Parser) gigo.Input {
+	return &input{Input: in, parser: p}
+}
+
+type input struct {
+	gigo.Input
+	parser Parser
+}
+
+func (i *input) Start(e gigo.Emitter) error {
+	return i.Input.Start(gigo.EmitterFunc(func(record *gigo.Record) error {
+		perr := i.parser.Parse(record)
+		if err := e.Emit(record); err != nil {
+			return err
+		}
+		return perr
+	}))
+}
+
+// Reload reloads in if it implements gigo.Reloader.
+func (i *input) Reload() error {
+	if r, ok := i.Input.(gigo.Reloader); ok {
+		return r.Reload()
+	}
+	return nil
+}
+
+// Filter returns a filter parsing records.
+// A record failing to parse is dropped with the error.
+func Filter(p Parser) gigo.Filter {
+	return &filter{parser: p}
+}
+
+type filter struct {
+	parser Parser
+}
+
+func (f *filter) Filter(record *gigo.Record) (*gigo.Record, error) {
+	if err := f.parser.Parse(record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func newFilter(config gigo.PluginConfig) (gigo.Filter, error) {
+	p, err := New(config)
+	if err != nil {
+		return nil, err
+	}
+	return Filter(p), nil
+}
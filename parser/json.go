@@ -0,0 +1,32 @@
+package parser
+
+import (
+	"encoding/json"
+
+	"github.com/najeira/gigo"
+)
+
+func init() {
+	Register("json", newJSONParser)
+}
+
+// NewJSON returns a parser for a JSON object.
+func NewJSON(config Config) Parser {
+	return newParser(parseJSON, config)
+}
+
+func newJSONParser(config gigo.PluginConfig) (Parser, error) {
+	c, err := newConfig(config, "", "")
+	if err != nil {
+		return nil, err
+	}
+	return NewJSON(c), nil
+}
+
+func parseJSON(data []byte) (map[string]interface{}, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
@@ -0,0 +1,82 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/najeira/gigo"
+)
+
+func init() {
+	Register("logfmt", newLogfmtParser)
+}
+
+// NewLogfmt returns a parser for logfmt such as `level=info msg="hello world"`.
+// A key without a value is set to an empty string.
+func NewLogfmt(config Config) Parser {
+	return newParser(parseLogfmt, config)
+}
+
+func newLogfmtParser(config gigo.PluginConfig) (Parser, error) {
+	c, err := newConfig(config, "", "")
+	if err != nil {
+		return nil, err
+	}
+	return NewLogfmt(c), nil
+}
+
+func parseLogfmt(data []byte) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	s := string(data)
+	i := 0
+	for i < len(s) {
+		// skip spaces
+		for i < len(s) && s[i] <= ' ' {
+			i++
+		}
+		if i >= len(s) {
+			break
+		}
+
+		start := i
+		for i < len(s) && s[i] > ' ' && s[i] != '=' && s[i] != '"' {
+			i++
+		}
+		key := s[start:i]
+		if key == "" {
+			return nil, fmt.Errorf("parser: invalid logfmt at %d", i)
+		}
+		if i >= len(s) || s[i] != '=' {
+			fields[key] = ""
+			continue
+		}
+		i++ // skip '='
+
+		if i < len(s) && s[i] == '"' {
+			end := i + 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return nil, fmt.Errorf("parser: unterminated quote at %d", i)
+			}
+			value, err := strconv.Unquote(s[i : end+1])
+			if err != nil {
+				return nil, err
+			}
+			fields[key] = value
+			i = end + 1
+			continue
+		}
+
+		start = i
+		for i < len(s) && s[i] > ' ' {
+			i++
+		}
+		fields[key] = s[start:i]
+	}
+	return fields, nil
+}
@@ -0,0 +1,37 @@
+package parser
+
+import (
+	"bytes"
+
+	"github.com/najeira/gigo"
+)
+
+func init() {
+	Register("ltsv", newLTSVParser)
+}
+
+// NewLTSV returns a parser for Labeled Tab-separated Values.
+func NewLTSV(config Config) Parser {
+	return newParser(parseLTSV, config)
+}
+
+func newLTSVParser(config gigo.PluginConfig) (Parser, error) {
+	c, err := newConfig(config, "", "")
+	if err != nil {
+		return nil, err
+	}
+	return NewLTSV(c), nil
+}
+
+func parseLTSV(data []byte) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	for _, item := range bytes.Split(data, []byte{'\t'}) {
+		i := bytes.IndexByte(item, ':')
+		if i <= 0 {
+			// skip an item without a label
+			continue
+		}
+		fields[string(item[:i])] = string(item[i+1:])
+	}
+	return fields, nil
+}
@@ -0,0 +1,176 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/najeira/gigo"
+)
+
+var (
+	ErrNoTime = errors.New("parser: time field not found")
+)
+
+// Parser parses the raw bytes of a record into its fields.
+type Parser interface {
+	Parse(record *gigo.Record) error
+}
+
+// Config is the common configuration of parsers.
+type Config struct {
+	// TimeKey is the field holding the event time. The time is not
+	// extracted if empty.
+	TimeKey string
+
+	// TimeLayout is a layout for time.Parse, or "unix" and "unixmilli"
+	// for epoch numbers. Default is time.RFC3339.
+	TimeLayout string
+
+	// Location is used if the time has no zone. Default is time.Local.
+	Location *time.Location
+
+	// KeepTimeKey keeps the time field after the extraction.
+	KeepTimeKey bool
+}
+
+type parseFunc func(data []byte) (map[string]interface{}, error)
+
+// parser extracts the time from the fields returned by parse.
+type parser struct {
+	parse       parseFunc
+	timeKey     string
+	timeLayout  string
+	location    *time.Location
+	keepTimeKey bool
+}
+
+func newParser(parse parseFunc, config Config) *parser {
+	p := &parser{
+		parse:       parse,
+		timeKey:     config.TimeKey,
+		timeLayout:  config.TimeLayout,
+		location:    config.Location,
+		keepTimeKey: config.KeepTimeKey,
+	}
+	if p.timeLayout == "" {
+		p.timeLayout = time.RFC3339
+	}
+	if p.location == nil {
+		p.location = time.Local
+	}
+	return p
+}
+
+func (p *parser) Parse(record *gigo.Record) error {
+	fields, err := p.parse(record.Raw)
+	if err != nil {
+		return err
+	}
+	for key, value := range fields {
+		record.Set(key, value)
+	}
+
+	if p.timeKey == "" {
+		return nil
+	}
+	value, ok := record.Get(p.timeKey)
+	if !ok {
+		return ErrNoTime
+	}
+	t, err := p.parseTime(value)
+	if err != nil {
+		return err
+	}
+	record.Time = t
+	if !p.keepTimeKey {
+		record.Delete(p.timeKey)
+	}
+	return nil
+}
+
+func (p *parser) parseTime(value interface{}) (time.Time, error) {
+	switch p.timeLayout {
+	case "unix":
+		f, err := toFloat(value)
+		if err != nil {
+			return time.Time{}, err
+		}
+		sec := int64(f)
+		return time.Unix(sec, int64((f-float64(sec))*float64(time.Second))), nil
+	case "unixmilli":
+		f, err := toFloat(value)
+		if err != nil {
+			return time.Time{}, err
+		}
+		ms := int64(f)
+		return time.Unix(ms/1000, (ms%1000)*int64(time.Millisecond)), nil
+	}
+	s, ok := value.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("parser: invalid time %v", value)
+	}
+	return time.ParseInLocation(p.timeLayout, s, p.location)
+}
+
+func toFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case int64:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case string:
+		return strconv.ParseFloat(v, 64)
+	}
+	return 0, fmt.Errorf("parser: invalid time %v", value)
+}
+
+// Factory creates a parser from the settings of a plugin.
+type Factory func(config gigo.PluginConfig) (Parser, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[string]Factory)
+)
+
+// Register makes a parser available by the format name.
+func Register(name string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	if _, dup := factories[name]; dup {
+		panic("parser: Register called twice for " + name)
+	}
+	factories[name] = factory
+}
+
+// New creates a parser by the "format" setting.
+func New(config gigo.PluginConfig) (Parser, error) {
+	name := config.String("format", "")
+	factoriesMu.RLock()
+	factory, ok := factories[name]
+	factoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("parser: unknown format %s", name)
+	}
+	return factory(config)
+}
+
+func newConfig(config gigo.PluginConfig, timeKey, timeLayout string) (Config, error) {
+	c := Config{
+		TimeKey:     config.String("time_key", timeKey),
+		TimeLayout:  config.String("time_layout", timeLayout),
+		KeepTimeKey: config.Bool("keep_time_key", false),
+	}
+	if name := config.String("time_location", ""); name != "" {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			return c, err
+		}
+		c.Location = loc
+	}
+	return c, nil
+}
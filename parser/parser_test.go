@@ -0,0 +1,152 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/najeira/gigo"
+)
+
+func parse(t *testing.T, p Parser, raw string) *gigo.Record {
+	r := gigo.NewRecord("tag", []byte(raw))
+	if err := p.Parse(r); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func checkFields(t *testing.T, r *gigo.Record, expects map[string]string) {
+	for key, expect := range expects {
+		if v := r.GetString(key); v != expect {
+			t.Errorf("invalid %s: %q expect %q", key, v, expect)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	p := NewJSON(Config{TimeKey: "time"})
+	r := parse(t, p, `{"time":"2016-01-02T03:04:05Z","message":"hello"}`)
+	checkFields(t, r, map[string]string{"message": "hello"})
+	if _, ok := r.Get("time"); ok {
+		t.Error("time key is kept")
+	}
+	if exp := time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC); !r.Time.Equal(exp) {
+		t.Errorf("invalid time %s", r.Time)
+	}
+
+	p = NewJSON(Config{TimeKey: "ts", TimeLayout: "unixmilli", KeepTimeKey: true})
+	r = parse(t, p, `{"ts":1451703845123}`)
+	if r.Time.UnixNano() != 1451703845123*int64(time.Millisecond) {
+		t.Errorf("invalid time %s", r.Time)
+	}
+	if _, ok := r.Get("ts"); !ok {
+		t.Error("time key is not kept")
+	}
+
+	if err := NewJSON(Config{}).Parse(gigo.NewRecord("tag", []byte("{"))); err == nil {
+		t.Error("no error for invalid json")
+	}
+	if err := NewJSON(Config{TimeKey: "time"}).Parse(gigo.NewRecord("tag", []byte("{}"))); err != ErrNoTime {
+		t.Errorf("invalid error: %v", err)
+	}
+}
+
+func TestLogfmt(t *testing.T) {
+	r := parse(t, NewLogfmt(Config{}), `level=info msg="hello \"world\"" empty= flag`)
+	checkFields(t, r, map[string]string{
+		"level": "info",
+		"msg":   `hello "world"`,
+		"empty": "",
+		"flag":  "",
+	})
+
+	if err := NewLogfmt(Config{}).Parse(gigo.NewRecord("tag", []byte(`a="b`))); err == nil {
+		t.Error("no error for unterminated quote")
+	}
+}
+
+func TestLTSV(t *testing.T) {
+	r := parse(t, NewLTSV(Config{}), "host:127.0.0.1\tpath:/a:b\treq")
+	checkFields(t, r, map[string]string{
+		"host": "127.0.0.1",
+		"path": "/a:b",
+	})
+	if len(r.Fields) != 2 {
+		t.Errorf("invalid fields: %v", r.Fields)
+	}
+}
+
+func TestCSV(t *testing.T) {
+	p, err := NewCSV(CSVConfig{Columns: []string{"a", "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := parse(t, p, `1,"x,y",3`)
+	checkFields(t, r, map[string]string{"a": "1", "b": "x,y"})
+
+	if _, err := NewCSV(CSVConfig{}); err == nil {
+		t.Error("no error without columns")
+	}
+}
+
+func TestRegexp(t *testing.T) {
+	p, err := NewRegexp(`^(?P<level>\w+): (?P<message>.*)$`, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := parse(t, p, "ERROR: failed")
+	checkFields(t, r, map[string]string{"level": "ERROR", "message": "failed"})
+
+	if err := p.Parse(gigo.NewRecord("tag", []byte("nomatch"))); err == nil {
+		t.Error("no error for unmatched line")
+	}
+	if _, err := NewRegexp(`^\w+$`, Config{}); err == nil {
+		t.Error("no error without named groups")
+	}
+}
+
+func TestApache(t *testing.T) {
+	p := NewApache(Config{TimeKey: "time", TimeLayout: CombinedTimeLayout})
+	r := parse(t, p, `192.168.0.1 - - [28/Feb/2013:12:00:00 +0900] "GET / HTTP/1.1" 200 777 "-" "Opera/12.0"`)
+	checkFields(t, r, map[string]string{
+		"host":    "192.168.0.1",
+		"user":    "-",
+		"method":  "GET",
+		"path":    "/",
+		"code":    "200",
+		"size":    "777",
+		"referer": "-",
+		"agent":   "Opera/12.0",
+	})
+	if r.Time.Unix() != 1362020400 {
+		t.Errorf("invalid time %s", r.Time)
+	}
+}
+
+func TestNginx(t *testing.T) {
+	p := NewNginx(Config{})
+	r := parse(t, p, `127.0.0.1 192.168.0.1 - [28/Feb/2013:12:00:00 +0900] "GET / HTTP/1.1" 200 777 "-" "Opera/12.0" -`)
+	checkFields(t, r, map[string]string{
+		"remote": "127.0.0.1",
+		"host":   "192.168.0.1",
+		"method": "GET",
+		"code":   "200",
+		"agent":  "Opera/12.0",
+		"time":   "28/Feb/2013:12:00:00 +0900",
+	})
+}
+
+func TestNew(t *testing.T) {
+	p, err := New(gigo.PluginConfig{"format": "ltsv", "time_key": "time", "time_layout": "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := parse(t, p, "time:1451703845\tmessage:hi")
+	if r.Time.Unix() != 1451703845 {
+		t.Errorf("invalid time %s", r.Time)
+	}
+
+	if _, err := New(gigo.PluginConfig{"format": "unknown"}); err == nil {
+		t.Error("no error for unknown format")
+	}
+}
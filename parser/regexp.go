@@ -0,0 +1,98 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/najeira/gigo"
+)
+
+const (
+	// ApachePattern matches the Apache combined log format.
+	ApachePattern = `^(?P<host>[^ ]*) [^ ]* (?P<user>[^ ]*) \[(?P<time>[^\]]*)\] "(?P<method>\S+)(?: +(?P<path>(?:[^"]|\\.)*?)(?: +\S*)?)?" (?P<code>[^ ]*) (?P<size>[^ ]*)(?: "(?P<referer>(?:[^"]|\\.)*)" "(?P<agent>(?:[^"]|\\.)*)")?$`
+
+	// NginxPattern matches the Nginx combined log format.
+	NginxPattern = `^(?P<remote>[^ ]*) (?P<host>[^ ]*) (?P<user>[^ ]*) \[(?P<time>[^\]]*)\] "(?P<method>\S+)(?: +(?P<path>[^"]*?)(?: +\S*)?)?" (?P<code>[^ ]*) (?P<size>[^ ]*)(?: "(?P<referer>[^"]*)" "(?P<agent>[^"]*)"(?:\s+(?P<http_x_forwarded_for>[^ ]+))?)?$`
+
+	// CombinedTimeLayout is the time layout of the combined log format.
+	CombinedTimeLayout = "02/Jan/2006:15:04:05 -0700"
+)
+
+func init() {
+	Register("regexp", newRegexpParser)
+	Register("apache", newApacheParser)
+	Register("nginx", newNginxParser)
+}
+
+// NewRegexp returns a parser setting the named groups of the pattern as fields.
+func NewRegexp(pattern string, config Config) (Parser, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	names := re.SubexpNames()
+	hasName := false
+	for _, name := range names {
+		if name != "" {
+			hasName = true
+		}
+	}
+	if !hasName {
+		return nil, errors.New("parser: no named group in the pattern")
+	}
+
+	parse := func(data []byte) (map[string]interface{}, error) {
+		match := re.FindSubmatch(data)
+		if match == nil {
+			return nil, fmt.Errorf("parser: not match %s", data)
+		}
+		fields := make(map[string]interface{}, len(names))
+		for i, name := range names {
+			if name != "" && match[i] != nil {
+				fields[name] = string(match[i])
+			}
+		}
+		return fields, nil
+	}
+	return newParser(parse, config), nil
+}
+
+// NewApache returns a parser for the Apache combined log format.
+func NewApache(config Config) Parser {
+	p, _ := NewRegexp(ApachePattern, config)
+	return p
+}
+
+// NewNginx returns a parser for the Nginx combined log format.
+func NewNginx(config Config) Parser {
+	p, _ := NewRegexp(NginxPattern, config)
+	return p
+}
+
+func newRegexpParser(config gigo.PluginConfig) (Parser, error) {
+	if err := config.Require("pattern"); err != nil {
+		return nil, err
+	}
+	c, err := newConfig(config, "", "")
+	if err != nil {
+		return nil, err
+	}
+	return NewRegexp(config.String("pattern", ""), c)
+}
+
+func newApacheParser(config gigo.PluginConfig) (Parser, error) {
+	c, err := newConfig(config, "time", CombinedTimeLayout)
+	if err != nil {
+		return nil, err
+	}
+	return NewApache(c), nil
+}
+
+func newNginxParser(config gigo.PluginConfig) (Parser, error) {
+	c, err := newConfig(config, "time", CombinedTimeLayout)
+	if err != nil {
+		return nil, err
+	}
+	return NewNginx(c), nil
+}
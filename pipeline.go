@@ -0,0 +1,263 @@
+package gigo
+
+import (
+	"sync"
+)
+
+const (
+	pipelineName       = "pipeline"
+	pipelineBufferSize = 1000
+)
+
+var (
+	_ Emitter = (*Pipeline)(nil)
+)
+
+// Pipeline runs inputs and outputs, and routes records emitted by
+// the inputs to every output whose pattern matches the record's tag.
+// Before routing, records pass through the matching filters in order.
+//
+// Outputs are started before inputs. On Stop, inputs are stopped first,
+// then the records already routed are drained and the outputs are stopped.
+type Pipeline struct {
+	Mixin
+
+	inputs  []Input
+	filters []*filterRoute
+	routes  []*route
+
+	mu      sync.RWMutex
+	started bool
+	wg      sync.WaitGroup
+}
+
+type route struct {
+	matcher *Matcher
+	output  Output
+	ch      chan *Record
+}
+
+type filterRoute struct {
+	matcher *Matcher
+	filter  Filter
+}
+
+func NewPipeline() *Pipeline {
+	p := &Pipeline{}
+	p.Name = pipelineName
+	return p
+}
+
+func (p *Pipeline) AddInput(input Input) {
+	p.inputs = append(p.inputs, input)
+}
+
+// AddFilter adds a filter applied to records whose tag matches pattern.
+func (p *Pipeline) AddFilter(pattern string, filter Filter) error {
+	m, err := NewMatcher(pattern)
+	if err != nil {
+		return err
+	}
+	p.filters = append(p.filters, &filterRoute{matcher: m, filter: filter})
+	return nil
+}
+
+// AddOutput adds an output receiving records whose tag matches pattern.
+func (p *Pipeline) AddOutput(pattern string, output Output) error {
+	m, err := NewMatcher(pattern)
+	if err != nil {
+		return err
+	}
+	p.routes = append(p.routes, &route{matcher: m, output: output})
+	return nil
+}
+
+func (p *Pipeline) Start() error {
+	if err := p.startOutputs(); err != nil {
+		return err
+	}
+
+	for i, input := range p.inputs {
+		if err := input.Start(p); err != nil {
+			p.Errorf("input start error %s", err)
+			for _, started := range p.inputs[:i] {
+				started.Stop()
+			}
+			p.stopOutputs()
+			return err
+		}
+	}
+
+	p.Infof("start %d inputs %d outputs", len(p.inputs), len(p.routes))
+	return nil
+}
+
+func (p *Pipeline) startOutputs() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.started {
+		return ErrAlreadyStarted
+	}
+
+	for i, rt := range p.routes {
+		if err := rt.output.Start(); err != nil {
+			p.Errorf("output start error %s", err)
+			for _, started := range p.routes[:i] {
+				started.output.Stop()
+			}
+			return err
+		}
+	}
+
+	for _, rt := range p.routes {
+		rt.ch = make(chan *Record, pipelineBufferSize)
+		p.wg.Add(1)
+		go p.drain(rt)
+	}
+	p.started = true
+	return nil
+}
+
+func (p *Pipeline) drain(rt *route) {
+	defer p.wg.Done()
+	for record := range rt.ch {
+		if err := rt.output.Emit(record); err != nil {
+			p.Errorf("emit error %s", err)
+		}
+	}
+}
+
+// Emit filters the record and routes it to the matching outputs.
+func (p *Pipeline) Emit(record *Record) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if !p.started {
+		return ErrNotStarted
+	}
+
+	for _, fr := range p.filters {
+		if !fr.matcher.Match(record.Tag) {
+			continue
+		}
+		filtered, err := fr.filter.Filter(record)
+		if err != nil {
+			p.Errorf("filter error %s", err)
+			return err
+		} else if filtered == nil {
+			return nil
+		}
+		record = filtered
+	}
+
+	var matched []*route
+	for _, rt := range p.routes {
+		if rt.matcher.Match(record.Tag) {
+			matched = append(matched, rt)
+		}
+	}
+	if len(matched) <= 0 {
+		p.Debugf("no output matches %s", record.Tag)
+		return nil
+	}
+
+	// each output owns its record, so copy all before any output
+	// can modify the original
+	records := make([]*Record, len(matched))
+	records[0] = record
+	for i := 1; i < len(records); i++ {
+		records[i] = record.Copy()
+	}
+	for i, rt := range matched {
+		rt.ch <- records[i]
+	}
+	return nil
+}
+
+func (p *Pipeline) Stop() error {
+	p.mu.RLock()
+	started := p.started
+	p.mu.RUnlock()
+	if !started {
+		return ErrNotStarted
+	}
+
+	var lastErr error
+	for _, input := range p.inputs {
+		if err := input.Stop(); err != nil {
+			p.Errorf("input stop error %s", err)
+			lastErr = err
+		}
+	}
+
+	if err := p.stopOutputs(); err != nil {
+		lastErr = err
+	}
+
+	p.Info("stop")
+	return lastErr
+}
+
+func (p *Pipeline) stopOutputs() error {
+	p.mu.Lock()
+	p.started = false
+	for _, rt := range p.routes {
+		close(rt.ch)
+	}
+	p.mu.Unlock()
+
+	p.wg.Wait()
+
+	var lastErr error
+	for _, rt := range p.routes {
+		if err := rt.output.Stop(); err != nil {
+			p.Errorf("output stop error %s", err)
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// Reload reloads the inputs, filters and outputs implementing Reloader.
+func (p *Pipeline) Reload() error {
+	plugins := make([]interface{}, 0, len(p.inputs)+len(p.filters)+len(p.routes))
+	for _, input := range p.inputs {
+		plugins = append(plugins, input)
+	}
+	for _, fr := range p.filters {
+		plugins = append(plugins, fr.filter)
+	}
+	for _, rt := range p.routes {
+		plugins = append(plugins, rt.output)
+	}
+
+	var lastErr error
+	for _, plugin := range plugins {
+		if r, ok := plugin.(Reloader); ok {
+			if err := r.Reload(); err != nil {
+				p.Errorf("reload error %s", err)
+				lastErr = err
+			}
+		}
+	}
+	return lastErr
+}
+
+// Health returns the first error reported by the inputs or outputs.
+func (p *Pipeline) Health() error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if !p.started {
+		return ErrNotStarted
+	}
+	for _, input := range p.inputs {
+		if err := input.Health(); err != nil {
+			return err
+		}
+	}
+	for _, rt := range p.routes {
+		if err := rt.output.Health(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
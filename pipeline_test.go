@@ -0,0 +1,208 @@
+package gigo
+
+import (
+	"sync"
+	"testing"
+)
+
+type testInput struct {
+	records []*Record
+	emitter Emitter
+}
+
+func (i *testInput) Start(e Emitter) error {
+	i.emitter = e
+	return nil
+}
+
+func (i *testInput) Stop() error {
+	for _, record := range i.records {
+		i.emitter.Emit(record)
+	}
+	return nil
+}
+
+func (i *testInput) Health() error {
+	return nil
+}
+
+type testOutput struct {
+	mu      sync.Mutex
+	tags    []string
+	stopped bool
+}
+
+func (o *testOutput) Start() error {
+	return nil
+}
+
+func (o *testOutput) Stop() error {
+	o.stopped = true
+	return nil
+}
+
+func (o *testOutput) Emit(record *Record) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.tags = append(o.tags, record.Tag)
+	return nil
+}
+
+func (o *testOutput) Health() error {
+	return nil
+}
+
+func TestPipeline(t *testing.T) {
+	// the input emits on Stop to check that outputs are drained after inputs
+	in := &testInput{records: []*Record{
+		NewRecord("app.access", nil),
+		NewRecord("app.error", nil),
+		NewRecord("sys", nil),
+	}}
+	all := &testOutput{}
+	access := &testOutput{}
+
+	p := NewPipeline()
+	p.AddInput(in)
+	if err := p.AddOutput("app.**", all); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AddOutput("*.access", access); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Health(); err != nil {
+		t.Error(err)
+	}
+	if err := p.Start(); err != ErrAlreadyStarted {
+		t.Errorf("invalid error: %v", err)
+	}
+	if err := p.Stop(); err != nil {
+		t.Error(err)
+	}
+
+	if len(all.tags) != 2 || all.tags[0] != "app.access" || all.tags[1] != "app.error" {
+		t.Errorf("invalid tags: %v", all.tags)
+	}
+	if len(access.tags) != 1 || access.tags[0] != "app.access" {
+		t.Errorf("invalid tags: %v", access.tags)
+	}
+	if !all.stopped || !access.stopped {
+		t.Error("outputs not stopped")
+	}
+
+	if err := p.Emit(NewRecord("app", nil)); err != ErrNotStarted {
+		t.Errorf("invalid error: %v", err)
+	}
+}
+
+func TestPipelineFilter(t *testing.T) {
+	in := &testInput{records: []*Record{
+		NewRecord("app.access", nil),
+		NewRecord("app.debug", nil),
+		NewRecord("sys", nil),
+	}}
+	out := &testOutput{}
+
+	p := NewPipeline()
+	p.AddInput(in)
+	p.AddFilter("app.debug", FilterFunc(func(record *Record) (*Record, error) {
+		return nil, nil
+	}))
+	p.AddFilter("sys", FilterFunc(func(record *Record) (*Record, error) {
+		record.Tag = "app.sys"
+		return record, nil
+	}))
+	if err := p.AddOutput("app.**", out); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Stop(); err != nil {
+		t.Error(err)
+	}
+
+	if len(out.tags) != 2 || out.tags[0] != "app.access" || out.tags[1] != "app.sys" {
+		t.Errorf("invalid tags: %v", out.tags)
+	}
+}
+
+// testModifyOutput modifies the records to check that each output
+// owns its record.
+type testModifyOutput struct {
+	testOutput
+	values []interface{}
+}
+
+func (o *testModifyOutput) Emit(record *Record) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.values = append(o.values, record.Fields["n"])
+	record.Fields["n"] = "modified"
+	return nil
+}
+
+func TestPipelineCopy(t *testing.T) {
+	var records []*Record
+	for i := 0; i < 100; i++ {
+		records = append(records, &Record{Tag: "app", Fields: map[string]interface{}{"n": i}})
+	}
+	in := &testInput{records: records}
+	outs := []*testModifyOutput{{}, {}, {}}
+
+	p := NewPipeline()
+	p.AddInput(in)
+	for _, out := range outs {
+		if err := p.AddOutput("app", out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Stop(); err != nil {
+		t.Error(err)
+	}
+
+	for _, out := range outs {
+		if len(out.values) != len(records) {
+			t.Fatalf("invalid records: %d", len(out.values))
+		}
+		for i, v := range out.values {
+			if v != i {
+				t.Errorf("invalid value %v expect %d", v, i)
+			}
+		}
+	}
+}
+
+type testReloadInput struct {
+	testInput
+	reloaded int
+}
+
+func (i *testReloadInput) Reload() error {
+	i.reloaded++
+	return nil
+}
+
+func TestPipelineReload(t *testing.T) {
+	in := &testReloadInput{}
+	p := NewPipeline()
+	p.AddInput(in)
+	p.AddInput(&testInput{})
+	if err := p.AddOutput("**", &testOutput{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Reload(); err != nil {
+		t.Error(err)
+	}
+	if in.reloaded != 1 {
+		t.Errorf("invalid reload %d", in.reloaded)
+	}
+}
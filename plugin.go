@@ -0,0 +1,45 @@
+package gigo
+
+import (
+	"errors"
+)
+
+var (
+	ErrNotStarted     = errors.New("gigo: not started")
+	ErrAlreadyStarted = errors.New("gigo: already started")
+)
+
+// Emitter receives records produced by an input.
+type Emitter interface {
+	Emit(record *Record) error
+}
+
+// EmitterFunc adapts a function to the Emitter interface.
+type EmitterFunc func(record *Record) error
+
+func (f EmitterFunc) Emit(record *Record) error {
+	return f(record)
+}
+
+// Input is a plugin that produces records and emits them to an Emitter.
+// Start must not block; the input runs in its own goroutines until Stop.
+type Input interface {
+	Start(e Emitter) error
+	Stop() error
+	Health() error
+}
+
+// Reloader is implemented by plugins that can reload their resources,
+// such as certificates, while running.
+type Reloader interface {
+	Reload() error
+}
+
+// Output is a plugin that consumes records.
+// Every Output is an Emitter, so an Input can be wired to it directly.
+type Output interface {
+	Emitter
+	Start() error
+	Stop() error
+	Health() error
+}
@@ -0,0 +1,84 @@
+package gigo
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Record is a unit of data flowing from inputs to outputs.
+type Record struct {
+	// Tag is used to route the record.
+	Tag string
+
+	// Time is the event time of the record.
+	Time time.Time
+
+	// Fields holds structured data and metadata such as the source.
+	Fields map[string]interface{}
+
+	// Raw is the original bytes read by the input, if any.
+	Raw []byte
+
+	// Modified is set by the filters changing Fields,
+	// after which Raw alone does not represent the record.
+	Modified bool
+}
+
+// NewRecord returns a Record with the current time and empty fields.
+func NewRecord(tag string, raw []byte) *Record {
+	return &Record{
+		Tag:    tag,
+		Time:   time.Now(),
+		Fields: make(map[string]interface{}),
+		Raw:    raw,
+	}
+}
+
+func (r *Record) Get(key string) (interface{}, bool) {
+	v, ok := r.Fields[key]
+	return v, ok
+}
+
+func (r *Record) GetString(key string) string {
+	switch v := r.Fields[key].(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+	return ""
+}
+
+func (r *Record) Set(key string, value interface{}) {
+	if r.Fields == nil {
+		r.Fields = make(map[string]interface{})
+	}
+	r.Fields[key] = value
+}
+
+func (r *Record) Delete(key string) {
+	delete(r.Fields, key)
+}
+
+// Copy returns a copy of the record. Fields are copied shallowly.
+func (r *Record) Copy() *Record {
+	c := &Record{
+		Tag:      r.Tag,
+		Time:     r.Time,
+		Fields:   make(map[string]interface{}, len(r.Fields)),
+		Raw:      r.Raw,
+		Modified: r.Modified,
+	}
+	for k, v := range r.Fields {
+		c.Fields[k] = v
+	}
+	return c
+}
+
+// Bytes returns Raw, or Fields encoded as JSON if Raw is nil.
+func (r *Record) Bytes() ([]byte, error) {
+	if r.Raw != nil {
+		return r.Raw, nil
+	}
+	return json.Marshal(r.Fields)
+}
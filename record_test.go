@@ -0,0 +1,44 @@
+package gigo
+
+import (
+	"testing"
+)
+
+func TestRecordCopy(t *testing.T) {
+	r := NewRecord("tag", []byte("raw"))
+	r.Set("a", "b")
+
+	c := r.Copy()
+	c.Set("a", "c")
+	c.Tag = "other"
+
+	if v := r.GetString("a"); v != "b" {
+		t.Errorf("invalid field: %s", v)
+	}
+	if r.Tag != "tag" {
+		t.Errorf("invalid tag: %s", r.Tag)
+	}
+	if v := c.GetString("a"); v != "c" {
+		t.Errorf("invalid field: %s", v)
+	}
+}
+
+func TestRecordBytes(t *testing.T) {
+	r := NewRecord("tag", []byte("raw"))
+	r.Set("a", "b")
+
+	b, err := r.Bytes()
+	if err != nil {
+		t.Error(err)
+	} else if string(b) != "raw" {
+		t.Errorf("invalid bytes: %s", b)
+	}
+
+	r.Raw = nil
+	b, err = r.Bytes()
+	if err != nil {
+		t.Error(err)
+	} else if string(b) != `{"a":"b"}` {
+		t.Errorf("invalid bytes: %s", b)
+	}
+}
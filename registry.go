@@ -0,0 +1,208 @@
+package gigo
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/najeira/conv"
+)
+
+// PluginConfig holds the settings of a plugin decoded from a config file.
+type PluginConfig map[string]interface{}
+
+// loggerKey holds the Logger of a PluginConfig.
+const loggerKey = "@logger"
+
+// SetLogger sets the logger given to the plugin created from c.
+func (c PluginConfig) SetLogger(l Logger) {
+	c[loggerKey] = l
+}
+
+// Logger returns the logger set by SetLogger, or nil.
+func (c PluginConfig) Logger() Logger {
+	l, _ := c[loggerKey].(Logger)
+	return l
+}
+
+func (c PluginConfig) Has(key string) bool {
+	_, ok := c[key]
+	return ok
+}
+
+func (c PluginConfig) String(key string, def string) string {
+	return conv.String(c[key], def)
+}
+
+func (c PluginConfig) Int(key string, def int64) int64 {
+	return conv.Int(c[key], def)
+}
+
+func (c PluginConfig) Bool(key string, def bool) bool {
+	return conv.Bool(c[key], def)
+}
+
+// Duration parses a value such as "10s". A number is taken as seconds.
+func (c PluginConfig) Duration(key string, def time.Duration) (time.Duration, error) {
+	switch v := c[key].(type) {
+	case nil:
+		return def, nil
+	case string:
+		return time.ParseDuration(v)
+	case int64:
+		return time.Duration(v) * time.Second, nil
+	case int:
+		return time.Duration(v) * time.Second, nil
+	case float64:
+		return time.Duration(v * float64(time.Second)), nil
+	}
+	return 0, fmt.Errorf("gigo: invalid duration %s", key)
+}
+
+// Strings returns a list of strings. A single string is taken as a list of one.
+func (c PluginConfig) Strings(key string) []string {
+	switch v := c[key].(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []interface{}:
+		ss := make([]string, 0, len(v))
+		for _, s := range v {
+			ss = append(ss, conv.String(s, ""))
+		}
+		return ss
+	}
+	return nil
+}
+
+// Map returns a table such as [output.fields].
+func (c PluginConfig) Map(key string) map[string]interface{} {
+	if m, ok := c[key].(map[string]interface{}); ok {
+		return m
+	}
+	return nil
+}
+
+// Require returns an error if any of the keys are not set.
+func (c PluginConfig) Require(keys ...string) error {
+	for _, key := range keys {
+		if c.String(key, "") == "" {
+			return fmt.Errorf("gigo: %s is not configured", key)
+		}
+	}
+	return nil
+}
+
+type InputFactory func(config PluginConfig) (Input, error)
+
+type OutputFactory func(config PluginConfig) (Output, error)
+
+type FilterFactory func(config PluginConfig) (Filter, error)
+
+var (
+	registryMu      sync.RWMutex
+	inputFactories  = make(map[string]InputFactory)
+	outputFactories = make(map[string]OutputFactory)
+	filterFactories = make(map[string]FilterFactory)
+)
+
+// RegisterInput makes an input available by the type name.
+// It is intended to be called from the init function of plugin packages.
+func RegisterInput(name string, factory InputFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := inputFactories[name]; dup {
+		panic("gigo: RegisterInput called twice for " + name)
+	}
+	inputFactories[name] = factory
+}
+
+// RegisterOutput makes an output available by the type name.
+// It is intended to be called from the init function of plugin packages.
+func RegisterOutput(name string, factory OutputFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := outputFactories[name]; dup {
+		panic("gigo: RegisterOutput called twice for " + name)
+	}
+	outputFactories[name] = factory
+}
+
+// RegisterFilter makes a filter available by the type name.
+// It is intended to be called from the init function of plugin packages.
+func RegisterFilter(name string, factory FilterFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := filterFactories[name]; dup {
+		panic("gigo: RegisterFilter called twice for " + name)
+	}
+	filterFactories[name] = factory
+}
+
+func NewInput(name string, config PluginConfig) (Input, error) {
+	registryMu.RLock()
+	factory, ok := inputFactories[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("gigo: unknown input %s", name)
+	}
+	return factory(config)
+}
+
+func NewOutput(name string, config PluginConfig) (Output, error) {
+	registryMu.RLock()
+	factory, ok := outputFactories[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("gigo: unknown output %s", name)
+	}
+	return factory(config)
+}
+
+func NewFilter(name string, config PluginConfig) (Filter, error) {
+	registryMu.RLock()
+	factory, ok := filterFactories[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("gigo: unknown filter %s", name)
+	}
+	return factory(config)
+}
+
+// Inputs returns the sorted type names of the registered inputs.
+func Inputs() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(inputFactories))
+	for name := range inputFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Outputs returns the sorted type names of the registered outputs.
+func Outputs() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(outputFactories))
+	for name := range outputFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Filters returns the sorted type names of the registered filters.
+func Filters() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(filterFactories))
+	for name := range filterFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
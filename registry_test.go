@@ -0,0 +1,66 @@
+package gigo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRegistry(t *testing.T) {
+	RegisterInput("test_registry", func(config PluginConfig) (Input, error) {
+		return &testInput{}, nil
+	})
+	RegisterOutput("test_registry", func(config PluginConfig) (Output, error) {
+		if err := config.Require("path"); err != nil {
+			return nil, err
+		}
+		return &testOutput{}, nil
+	})
+
+	if _, err := NewInput("test_registry", nil); err != nil {
+		t.Error(err)
+	}
+	if _, err := NewInput("unknown", nil); err == nil {
+		t.Error("no error for unknown input")
+	}
+	if _, err := NewOutput("test_registry", PluginConfig{}); err == nil {
+		t.Error("no error for missing path")
+	}
+	if _, err := NewOutput("test_registry", PluginConfig{"path": "a"}); err != nil {
+		t.Error(err)
+	}
+
+	found := false
+	for _, name := range Inputs() {
+		if name == "test_registry" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("not registered: %v", Inputs())
+	}
+}
+
+func TestPluginConfig(t *testing.T) {
+	c := PluginConfig{
+		"interval": "3s",
+		"seconds":  int64(2),
+		"list":     []interface{}{"a", "b"},
+		"single":   "c",
+	}
+
+	if d, err := c.Duration("interval", 0); err != nil || d != 3*time.Second {
+		t.Errorf("invalid duration: %s %v", d, err)
+	}
+	if d, err := c.Duration("seconds", 0); err != nil || d != 2*time.Second {
+		t.Errorf("invalid duration: %s %v", d, err)
+	}
+	if d, err := c.Duration("none", time.Minute); err != nil || d != time.Minute {
+		t.Errorf("invalid duration: %s %v", d, err)
+	}
+	if l := c.Strings("list"); len(l) != 2 || l[0] != "a" || l[1] != "b" {
+		t.Errorf("invalid list: %v", l)
+	}
+	if l := c.Strings("single"); len(l) != 1 || l[0] != "c" {
+		t.Errorf("invalid list: %v", l)
+	}
+}
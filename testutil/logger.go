@@ -3,30 +3,44 @@ package testutil
 import (
 	"bytes"
 	"fmt"
+	"sync"
 
 	"github.com/najeira/gigo"
 )
 
-type EventLogger struct {
-	buf bytes.Buffer
+var _ gigo.Logger = (*Logger)(nil)
+
+// mu guards the buffers of all loggers, so that a Logger can be
+// passed by value to check the logs.
+var mu sync.Mutex
+
+// Logger records the logs by level. Read the buffers after the plugin
+// logging to it is stopped.
+type Logger struct {
+	Debug bytes.Buffer
+	Info  bytes.Buffer
+	Warn  bytes.Buffer
+	Error bytes.Buffer
+}
+
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	l.printf(&l.Debug, format, args...)
 }
 
-var _ gigo.Eventer = (*EventLogger)(nil)
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.printf(&l.Info, format, args...)
+}
+
+func (l *Logger) Warnf(format string, args ...interface{}) {
+	l.printf(&l.Warn, format, args...)
+}
 
-func (e *EventLogger) Emit(tag string, level int, message string) {
-	if name := levelName(level); len(name) > 0 {
-		fmt.Fprintf("[%s] %s: %s\n", name, tag, message)
-	}
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.printf(&l.Error, format, args...)
 }
 
-func levelName(level int) string {
-	switch level {
-	case gigo.Debug:
-		return "debug"
-	case gigo.Info:
-		return "info"
-	case gigo.Err:
-		return "error"
-	}
-	return ""
+func (l *Logger) printf(buf *bytes.Buffer, format string, args ...interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
+	fmt.Fprintf(buf, format+"\n", args...)
 }